@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+// fake-node behaves like a VILLASnode WebRTC node to test the signaling server
+// and tools built around it without building VILLASnode.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/VILLASframework/signaling/pkg/node"
+	"github.com/pion/webrtc/v3"
+)
+
+// signalsFlag is a repeatable flag of signals in the form NAME[:TYPE[:UNIT]].
+type signalsFlag []pkg.Signal
+
+func (f *signalsFlag) String() string {
+	s := []string{}
+	for _, sig := range *f {
+		s = append(s, fmt.Sprintf("%s:%s:%s", sig.Name, sig.Type, sig.Unit))
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (f *signalsFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 3)
+
+	sig := pkg.Signal{
+		Name: parts[0],
+		Type: pkg.SignalTypeFloat,
+	}
+
+	if len(parts) > 1 {
+		switch typ := pkg.SignalType(parts[1]); typ {
+		case pkg.SignalTypeFloat, pkg.SignalTypeInteger, pkg.SignalTypeBoolean, pkg.SignalTypeComplex:
+			sig.Type = typ
+		default:
+			return fmt.Errorf("invalid signal type: %s", parts[1])
+		}
+	}
+
+	if len(parts) > 2 {
+		sig.Unit = parts[2]
+	}
+
+	*f = append(*f, sig)
+
+	return nil
+}
+
+// iceServersFlag is a repeatable flag of additional ICE server URLs.
+type iceServersFlag []webrtc.ICEServer
+
+func (f *iceServersFlag) String() string {
+	s := []string{}
+	for _, is := range *f {
+		s = append(s, is.URLs...)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (f *iceServersFlag) Set(value string) error {
+	*f = append(*f, webrtc.ICEServer{
+		URLs: []string{value},
+	})
+
+	return nil
+}
+
+func main() {
+	signals := signalsFlag{}
+	iceServers := iceServersFlag{}
+
+	server := flag.String("url", "ws://localhost:8080", "WebSocket URL of the signaling server")
+	session := flag.String("session", "", "Name of the session (required)")
+	peer := flag.String("peer", "fake-node", "Name of the peer")
+	flag.Var(&signals, "signal", "A signal in the form NAME[:TYPE[:UNIT]] (can be specified multiple times, defaults to a single float signal)")
+	flag.Var(&iceServers, "ice-server", "An additional ICE server URL like stun:stun.l.google.com:19302 (can be specified multiple times)")
+	rate := flag.Float64("rate", 10, "Samples sent per second (0 to only receive)")
+	echo := flag.Bool("echo", false, "Send received samples back to the remote peer")
+	ordered := flag.Bool("ordered", false, "Use an ordered data channel")
+	maxRetransmits := flag.Int("max-retransmits", -1, "Maximum number of retransmissions of the data channel (-1 for a reliable channel)")
+	samples := flag.Int64("samples", 0, "Exit successfully after receiving this number of samples (0 to run until interrupted)")
+	timeout := flag.Duration("timeout", 0, "Stop after this duration which is a failure if the expected samples were not received (0 for no timeout)")
+	printSamples := flag.Bool("print", false, "Print received samples as JSON lines to stdout")
+	level := flag.String("level", "info", "The log level (debug, info, warn, error)")
+	flag.Parse()
+
+	lvl := slog.LevelInfo
+	if err := lvl.UnmarshalText([]byte(*level)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid log level: %s\n", *level)
+		os.Exit(1)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: lvl,
+	}))
+
+	if *session == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if len(signals) == 0 {
+		signals = signalsFlag{{Name: "signal0", Type: pkg.SignalTypeFloat}}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if *timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, *timeout)
+		defer cancelTimeout()
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	enc := json.NewEncoder(os.Stdout)
+
+	cfg := node.Config{
+		URL:        *server,
+		Session:    *session,
+		Peer:       *peer,
+		Signals:    signals,
+		Rate:       *rate,
+		Echo:       *echo,
+		Ordered:    *ordered,
+		ICEServers: iceServers,
+		Logger:     logger,
+	}
+
+	if *maxRetransmits >= 0 {
+		mr := uint16(*maxRetransmits)
+		cfg.MaxRetransmits = &mr
+	}
+
+	var received int64
+
+	cfg.OnSample = func(smp node.Sample) {
+		if *printSamples {
+			enc.Encode(&smp) //nolint:errcheck
+		}
+
+		if received++; *samples > 0 && received >= *samples {
+			stop()
+		}
+	}
+
+	n := node.New(cfg)
+
+	err := n.Run(runCtx)
+
+	st := n.Stats()
+	logger.Info("Stopped",
+		slog.Int64("connections", st.Connections),
+		slog.Int64("negotiations", st.Negotiations),
+		slog.Int64("sent", st.SamplesSent),
+		slog.Int64("received", st.SamplesReceived))
+
+	switch {
+	case err != nil:
+		logger.Error("Node failed", slog.Any("error", err))
+		os.Exit(1)
+
+	case *samples > 0 && st.SamplesReceived < *samples:
+		logger.Error("Did not receive enough samples", slog.Int64("expected", *samples), slog.Int64("received", st.SamplesReceived))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+// loadgen simulates sessions of VILLASnode WebRTC nodes against a signaling server
+// to measure how many sessions a single instance can handle.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+func main() {
+	server := flag.String("url", "ws://localhost:8080", "WebSocket URL of the signaling server")
+	sessions := flag.Int("sessions", 10, "Number of sessions")
+	peers := flag.Int("peers", 2, "Number of peers per session")
+	rate := flag.Float64("rate", 1, "Negotiations per second and session")
+	candidates := flag.Int("candidates", 4, "Number of candidates sent after each description")
+	duration := flag.Duration("duration", 30*time.Second, "Duration of the message exchange")
+	connectRate := flag.Float64("connect-rate", 100, "Maximum number of new connections per second (0 for no limit)")
+	prefix := flag.String("prefix", "loadgen", "Prefix of the session names")
+	jsonOutput := flag.Bool("json", false, "Print the report as JSON")
+	flag.Parse()
+
+	u, err := url.Parse(*server)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid URL: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	s := &stats{}
+
+	fmt.Fprintf(os.Stderr, "Connecting %d peers in %d sessions\n", *sessions**peers, *sessions)
+
+	ps := []*peer{}
+	var throttle <-chan time.Time
+	if *connectRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *connectRate))
+		defer ticker.Stop()
+
+		throttle = ticker.C
+	}
+
+	wg := sync.WaitGroup{}
+	mutex := sync.Mutex{}
+
+connect:
+	for i := 0; i < *sessions; i++ {
+		for j := 0; j < *peers; j++ {
+			if throttle != nil {
+				select {
+				case <-throttle:
+				case <-ctx.Done():
+					break connect
+				}
+			}
+
+			p := &peer{
+				session: fmt.Sprintf("%s-%d", *prefix, i),
+				name:    fmt.Sprintf("peer-%d", j),
+				offerer: j == 0,
+				stats:   s,
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if err := p.Connect(ctx, u); err != nil {
+					s.connectErrors.Add(1)
+					fmt.Fprintf(os.Stderr, "Failed to connect %s/%s: %s\n", p.session, p.name, err)
+					return
+				}
+
+				mutex.Lock()
+				ps = append(ps, p)
+				mutex.Unlock()
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	fmt.Fprintf(os.Stderr, "Connected %d peers, exchanging messages for %s\n", len(ps), *duration)
+
+	runCtx, stop := context.WithTimeout(ctx, *duration)
+	defer stop()
+
+	// Reset message counters to only account for the exchange phase
+	s.sent.Store(0)
+	s.received.Store(0)
+	s.bytesSent.Store(0)
+	s.bytesReceived.Store(0)
+
+	start := time.Now()
+
+	for _, p := range ps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Run(runCtx, *rate, *candidates)
+		}()
+	}
+
+	<-runCtx.Done()
+	elapsed := time.Since(start)
+
+	wg.Wait()
+
+	r := s.Report(*sessions, *peers, elapsed)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r) //nolint:errcheck
+	} else {
+		printReport(os.Stdout, &r)
+	}
+
+	if r.ConnectErrors > 0 || r.WriteErrors > 0 || r.ReadErrors > 0 {
+		os.Exit(1)
+	}
+}
+
+func printReport(w io.Writer, r *report) {
+	fmt.Fprintf(w, "Sessions:      %d with %d peers each\n", r.Sessions, r.Peers)
+	fmt.Fprintf(w, "Connected:     %d\n", r.Connected)
+	fmt.Fprintf(w, "Duration:      %.1fs\n", r.Duration)
+	fmt.Fprintf(w, "Negotiations:  %d\n", r.Negotiations)
+	fmt.Fprintf(w, "Sent:          %d messages (%.1f/s), %d bytes\n", r.Sent, r.SentRate, r.BytesSent)
+	fmt.Fprintf(w, "Received:      %d messages (%.1f/s), %d bytes\n", r.Received, r.ReceivedRate, r.BytesReceived)
+	fmt.Fprintf(w, "Errors:        %d connect, %d write, %d read\n", r.ConnectErrors, r.WriteErrors, r.ReadErrors)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%-16s %8s %8s %8s %8s %8s %8s %8s\n", "LATENCY (ms)", "COUNT", "MIN", "MEAN", "P50", "P90", "P99", "MAX")
+
+	for _, l := range []struct {
+		name string
+		s    latencySummary
+	}{
+		{"connect", r.ConnectLatency},
+		{"forward", r.ForwardLatency},
+	} {
+		fmt.Fprintf(w, "%-16s %8d %8.2f %8.2f %8.2f %8.2f %8.2f %8.2f\n",
+			l.name, l.s.Count, l.s.Min, l.s.Mean, l.s.P50, l.s.P90, l.s.P99, l.s.Max)
+	}
+}
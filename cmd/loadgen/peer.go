@@ -0,0 +1,307 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/websocket"
+)
+
+// peer simulates a VILLASnode WebRTC node.
+// The offerer of a session periodically starts a negotiation by sending an offer followed by candidates.
+// All other peers reply to an offer with an answer followed by candidates.
+//
+// Each description and candidate carries its send time in the SDP origin
+// or candidate foundation to measure the forward latency at the receivers.
+type peer struct {
+	session string
+	name    string
+	offerer bool
+
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+	closing    bool
+
+	stats *stats
+}
+
+func (p *peer) Connect(ctx context.Context, server *url.URL) error {
+	u := server.JoinPath(url.PathEscape(p.session), url.PathEscape(p.name))
+
+	start := time.Now()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	p.conn = conn
+
+	if err := p.write(&pkg.SignalingMessage{
+		Signals: []pkg.Signal{
+			{Name: "voltage", Type: pkg.SignalTypeFloat, Unit: "V"},
+			{Name: "current", Type: pkg.SignalTypeFloat, Unit: "A"},
+		},
+	}); err != nil {
+		conn.Close() //nolint:errcheck
+		return err
+	}
+
+	// The server responds to the signals with the relays
+	// which are omitted if none are configured
+	msg := &pkg.SignalingMessage{}
+	if err := p.read(msg); err != nil {
+		conn.Close() //nolint:errcheck
+		return err
+	}
+
+	if msg.Control != nil || msg.Description != nil || msg.Candidate != nil {
+		conn.Close() //nolint:errcheck
+		return errors.New("expected relays message")
+	}
+
+	p.stats.connectLatency.Add(time.Since(start))
+	p.stats.connected.Add(1)
+
+	return nil
+}
+
+func (p *peer) write(msg *pkg.SignalingMessage) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	// Replies which are still in flight during shutdown are discarded
+	if p.closing {
+		return nil
+	}
+
+	if err := p.conn.WriteMessage(websocket.TextMessage, buf); err != nil {
+		p.stats.writeErrors.Add(1)
+		return err
+	}
+
+	p.stats.sent.Add(1)
+	p.stats.bytesSent.Add(int64(len(buf)))
+
+	return nil
+}
+
+func (p *peer) read(msg *pkg.SignalingMessage) error {
+	_, buf, err := p.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	p.stats.received.Add(1)
+	p.stats.bytesReceived.Add(int64(len(buf)))
+
+	return json.Unmarshal(buf, msg)
+}
+
+// Run exchanges messages until the context is cancelled.
+// rate is the number of negotiations per second started by an offerer.
+func (p *peer) Run(ctx context.Context, rate float64, candidates int) {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		p.readLoop(ctx, candidates)
+	}()
+
+	if p.offerer && rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+
+			case <-ticker.C:
+				p.negotiate("offer", candidates) //nolint:errcheck
+			}
+		}
+	} else {
+		<-ctx.Done()
+	}
+
+	p.writeMutex.Lock()
+	p.closing = true
+	err := p.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	p.writeMutex.Unlock()
+
+	if err != nil {
+		p.conn.Close() //nolint:errcheck
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		p.conn.Close() //nolint:errcheck
+		<-done
+	}
+}
+
+func (p *peer) readLoop(ctx context.Context, candidates int) {
+	for {
+		msg := &pkg.SignalingMessage{}
+		if err := p.read(msg); err != nil {
+			if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				p.stats.readErrors.Add(1)
+			}
+
+			return
+		}
+
+		now := time.Now()
+
+		switch {
+		case msg.Description != nil:
+			if ts, ok := sdpTimestamp(msg.Description.Spd); ok {
+				p.stats.forwardLatency.Add(now.Sub(ts))
+			}
+
+			switch msg.Description.Type {
+			case "offer":
+				if !p.offerer {
+					go p.negotiate("answer", candidates) //nolint:errcheck
+				}
+
+			case "answer":
+				if p.offerer {
+					p.stats.negotiations.Add(1)
+				}
+			}
+
+		case msg.Candidate != nil:
+			if ts, ok := candidateTimestamp(msg.Candidate.Spd); ok {
+				p.stats.forwardLatency.Add(now.Sub(ts))
+			}
+		}
+	}
+}
+
+// negotiate sends a description of the given type followed by a trickle of candidates.
+func (p *peer) negotiate(typ string, candidates int) error {
+	if err := p.write(&pkg.SignalingMessage{
+		Description: &pkg.DescriptionMessage{
+			Type: typ,
+			Spd:  sdp(typ, time.Now()),
+		},
+	}); err != nil {
+		return err
+	}
+
+	for i := 0; i < candidates; i++ {
+		if err := p.write(&pkg.SignalingMessage{
+			Candidate: &pkg.CandidateMessage{
+				Mid: "0",
+				Spd: candidate(i, time.Now()),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sdp returns a session description resembling the one of a VILLASnode data channel.
+func sdp(typ string, ts time.Time) string {
+	setup := "actpass"
+	if typ == "answer" {
+		setup = "active"
+	}
+
+	fp := make([]string, 32)
+	for i := range fp {
+		fp[i] = fmt.Sprintf("%02X", rand.Intn(256)) //nolint:gosec
+	}
+
+	return strings.Join([]string{
+		"v=0",
+		fmt.Sprintf("o=- %d 2 IN IP4 127.0.0.1", ts.UnixNano()),
+		"s=-",
+		"t=0 0",
+		"a=group:BUNDLE 0",
+		"a=extmap-allow-mixed",
+		"a=msid-semantic: WMS",
+		"m=application 9 UDP/DTLS/SCTP webrtc-datachannel",
+		"c=IN IP4 0.0.0.0",
+		"a=ice-ufrag:" + randomString(4),
+		"a=ice-pwd:" + randomString(24),
+		"a=ice-options:trickle",
+		"a=fingerprint:sha-256 " + strings.Join(fp, ":"),
+		"a=setup:" + setup,
+		"a=mid:0",
+		"a=sctp-port:5000",
+		"a=max-message-size:262144",
+		"",
+	}, "\r\n")
+}
+
+func candidate(i int, ts time.Time) string {
+	types := []string{"host", "srflx", "relay"}
+	typ := types[i%len(types)]
+
+	return fmt.Sprintf("candidate:%d 1 udp %d 10.%d.%d.%d %d typ %s generation 0",
+		ts.UnixNano(), 2122260223-i*1000,
+		rand.Intn(256), rand.Intn(256), rand.Intn(256), 49152+rand.Intn(16384), typ) //nolint:gosec
+}
+
+func sdpTimestamp(sdp string) (time.Time, bool) {
+	for _, line := range strings.Split(sdp, "\r\n") {
+		if rest, ok := strings.CutPrefix(line, "o=- "); ok {
+			return parseTimestamp(strings.SplitN(rest, " ", 2)[0])
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func candidateTimestamp(c string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(c, "candidate:")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return parseTimestamp(strings.SplitN(rest, " ", 2)[0])
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	ns, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, ns), true
+}
+
+func randomString(n int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789+/"
+
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[rand.Intn(len(chars))] //nolint:gosec
+	}
+
+	return string(b)
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencies collects duration samples for percentile calculation.
+type latencies struct {
+	samples []time.Duration
+	mutex   sync.Mutex
+}
+
+func (l *latencies) Add(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.samples = append(l.samples, d)
+}
+
+type latencySummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min_ms"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func (l *latencies) Summary() latencySummary {
+	l.mutex.Lock()
+	s := slices.Clone(l.samples)
+	l.mutex.Unlock()
+
+	if len(s) == 0 {
+		return latencySummary{}
+	}
+
+	slices.Sort(s)
+
+	var sum time.Duration
+	for _, d := range s {
+		sum += d
+	}
+
+	percentile := func(p float64) float64 {
+		return ms(s[int(p*float64(len(s)-1))])
+	}
+
+	return latencySummary{
+		Count: len(s),
+		Min:   ms(s[0]),
+		Mean:  ms(sum / time.Duration(len(s))),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+		Max:   ms(s[len(s)-1]),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1e3
+}
+
+// stats are shared by all simulated peers.
+type stats struct {
+	connectLatency latencies
+	forwardLatency latencies
+
+	connected    atomic.Int64
+	negotiations atomic.Int64
+
+	sent          atomic.Int64
+	received      atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+
+	connectErrors atomic.Int64
+	writeErrors   atomic.Int64
+	readErrors    atomic.Int64
+}
+
+type report struct {
+	Sessions int     `json:"sessions"`
+	Peers    int     `json:"peers"`
+	Duration float64 `json:"duration_s"`
+
+	Connected    int64 `json:"connected"`
+	Negotiations int64 `json:"negotiations"`
+
+	Sent          int64 `json:"messages_sent"`
+	Received      int64 `json:"messages_received"`
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+
+	SentRate     float64 `json:"messages_sent_per_s"`
+	ReceivedRate float64 `json:"messages_received_per_s"`
+
+	ConnectErrors int64 `json:"connect_errors"`
+	WriteErrors   int64 `json:"write_errors"`
+	ReadErrors    int64 `json:"read_errors"`
+
+	ConnectLatency latencySummary `json:"connect_latency"`
+	ForwardLatency latencySummary `json:"forward_latency"`
+}
+
+func (s *stats) Report(sessions, peers int, d time.Duration) report {
+	r := report{
+		Sessions:       sessions,
+		Peers:          peers,
+		Duration:       d.Seconds(),
+		Connected:      s.connected.Load(),
+		Negotiations:   s.negotiations.Load(),
+		Sent:           s.sent.Load(),
+		Received:       s.received.Load(),
+		BytesSent:      s.bytesSent.Load(),
+		BytesReceived:  s.bytesReceived.Load(),
+		ConnectErrors:  s.connectErrors.Load(),
+		WriteErrors:    s.writeErrors.Load(),
+		ReadErrors:     s.readErrors.Load(),
+		ConnectLatency: s.connectLatency.Summary(),
+		ForwardLatency: s.forwardLatency.Summary(),
+	}
+
+	if secs := d.Seconds(); secs > 0 {
+		r.SentRate = float64(r.Sent) / secs
+		r.ReceivedRate = float64(r.Received) / secs
+	}
+
+	return r
+}
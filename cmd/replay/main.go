@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+// replay plays back the messages which one peer sent during a recorded session
+// against a live peer while preserving their original timing.
+// Messages received from the live peer are written to stdout in the recording format.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/websocket"
+)
+
+func main() {
+	server := flag.String("url", "ws://localhost:8080", "WebSocket URL of the signaling server")
+	peer := flag.String("peer", "", "Name of the recorded peer whose messages are replayed (required if the recording contains multiple senders)")
+	session := flag.String("session", "", "Session to join (defaults to the recorded session)")
+	as := flag.String("as", "", "Peer name used for joining (defaults to the recorded peer)")
+	speed := flag.Float64("speed", 1, "Playback speed factor")
+	wait := flag.Bool("wait", true, "Wait for another peer to connect before replaying")
+	linger := flag.Duration("linger", 10*time.Second, "Time to wait for responses after the last message")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [options] RECORDING\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *speed <= 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, flag.Arg(0), *server, *peer, *session, *as, *speed, *wait, *linger); err != nil {
+		slog.Error("Replay failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, path, server, peer, session, as string, speed float64, wait bool, linger time.Duration) error {
+	records, err := readRecording(path)
+	if err != nil {
+		return err
+	}
+
+	if peer == "" {
+		if peer, err = onlySender(records); err != nil {
+			return err
+		}
+	}
+
+	if session == "" {
+		session = records[0].Session
+	}
+
+	if as == "" {
+		as = peer
+	}
+
+	signals := &pkg.SignalingMessage{}
+	msgs := []pkg.Record{}
+	for _, r := range records {
+		if r.Sender != peer {
+			continue
+		}
+
+		if r.Message.Signals != nil && len(msgs) == 0 {
+			signals.Signals = r.Message.Signals
+		} else if r.Message.Description != nil || r.Message.Candidate != nil {
+			// Do not attach the replayed messages to the recorded trace
+			r.Message.Trace = nil
+			msgs = append(msgs, r)
+		}
+	}
+
+	if len(msgs) == 0 {
+		return fmt.Errorf("no messages of peer '%s' in recording", peer)
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	u = u.JoinPath(url.PathEscape(session), url.PathEscape(as))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(signals); err != nil {
+		return fmt.Errorf("failed to send signals: %w", err)
+	}
+
+	slog.Info("Connected", slog.String("session", session), slog.String("peer", as))
+
+	ready := make(chan struct{})
+	closed := make(chan struct{})
+	go readLoop(conn, session, as, ready, closed)
+
+	if wait {
+		slog.Info("Waiting for a remote peer")
+
+		select {
+		case <-ready:
+		case <-closed:
+			return errors.New("connection closed")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	slog.Info("Replaying messages", slog.Int("count", len(msgs)), slog.Float64("speed", speed))
+
+	start := time.Now()
+	base := msgs[0].Time
+
+	for _, r := range msgs {
+		due := start.Add(time.Duration(float64(r.Time.Sub(base)) / speed))
+
+		select {
+		case <-time.After(time.Until(due)):
+		case <-closed:
+			return errors.New("connection closed")
+		case <-ctx.Done():
+			return nil
+		}
+
+		if err := conn.WriteJSON(&r.Message); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		slog.Debug("Sent message", slog.String("message", r.Message.String()))
+	}
+
+	slog.Info("Replay finished, waiting for responses", slog.Duration("linger", linger))
+
+	select {
+	case <-time.After(linger):
+	case <-closed:
+	case <-ctx.Done():
+	}
+
+	if err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second)); err != nil {
+		return err
+	}
+
+	// Wait for the server to acknowledge the close
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+	}
+
+	return nil
+}
+
+// readLoop writes received messages to stdout and closes ready once another peer is connected.
+func readLoop(conn *websocket.Conn, session, as string, ready, closed chan struct{}) {
+	defer close(closed)
+
+	enc := json.NewEncoder(os.Stdout)
+	isReady := false
+
+	for {
+		msg := pkg.SignalingMessage{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				slog.Error("Failed to read", slog.Any("error", err))
+			}
+
+			return
+		}
+
+		enc.Encode(&pkg.Record{ //nolint:errcheck
+			Time:       time.Now(),
+			Session:    session,
+			Recipients: []string{as},
+			Message:    msg,
+		})
+
+		if msg.Control != nil && !isReady {
+			if slices.ContainsFunc(msg.Control.Peers, func(p pkg.Peer) bool {
+				return p.Name != as && !p.Connected.IsZero()
+			}) {
+				isReady = true
+				close(ready)
+			}
+		}
+	}
+}
+
+func readRecording(path string) ([]pkg.Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []pkg.Record{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		r := pkg.Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("invalid record in line %d: %w", line, err)
+		}
+
+		records = append(records, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("recording is empty")
+	}
+
+	return records, nil
+}
+
+// onlySender returns the sender of all peer messages in a recording or an error if there are several.
+func onlySender(records []pkg.Record) (string, error) {
+	senders := map[string]struct{}{}
+	for _, r := range records {
+		if r.Sender != "" {
+			senders[r.Sender] = struct{}{}
+		}
+	}
+
+	names := []string{}
+	for name := range senders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if len(names) != 1 {
+		return "", fmt.Errorf("select one of the recorded peers with -peer: %v", names)
+	}
+
+	return names[0], nil
+}
@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// Flags
+	adminTokenFile string
+	metricsPublic  bool
+
+	adminTokens []string
+
+	errAdminDisabled = errors.New("admin API requires an admin token or a separate admin listener")
+)
+
+type apiLogLevelResponse struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+type apiGoroutinesResponse struct {
+	Count int `json:"count"`
+}
+
+type apiWebhookDeliveriesResponse struct {
+	Deliveries []pkg.WebhookDelivery `json:"deliveries"`
+}
+
+func loadAdminTokens() error {
+	if adminTokenFile == "" {
+		return nil
+	}
+
+	tokens, err := pkg.ReadSecretFile(adminTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to load admin tokens: %w", err)
+	}
+
+	adminTokens = tokens
+
+	return nil
+}
+
+// checkAdminToken compares the token in constant time against all admin tokens.
+func checkAdminToken(token string) bool {
+	h := sha256.Sum256([]byte(token))
+
+	ok := false
+	for _, t := range adminTokens {
+		th := sha256.Sum256([]byte(t))
+		if subtle.ConstantTimeCompare(h[:], th[:]) == 1 {
+			ok = true
+		}
+	}
+
+	return ok
+}
+
+// requireAdmin authenticates requests to the admin API with the admin tokens.
+// Independent of the API keys and OpenID Connect, the admin API is protected by its own tokens.
+// Without tokens, it is only accessible via trusted admin listeners.
+func requireAdmin(trusted bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(adminTokens) == 0 {
+			if !trusted {
+				writeError(w, http.StatusForbidden, errAdminDisabled)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := bearerToken(r)
+		if err == nil && !checkAdminToken(token) {
+			err = errUnknownCredentials
+		}
+
+		if err != nil {
+			audit(r, AuditAuthFailure, "", "", fmt.Errorf("admin: %w", err))
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="villas-signaling-admin"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized: %w", err))
+			return
+		}
+
+		r = withIdentity(r, &Identity{
+			Name:   "admin",
+			Scopes: []Scope{ScopeAdmin},
+		})
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addAdminRoutes registers the admin API below /admin.
+// trusted is set for the router of the admin listeners.
+func addAdminRoutes(r *mux.Router, trusted bool) {
+	ad := r.PathPrefix("/admin").Subrouter()
+
+	ad.Use(func(next http.Handler) http.Handler {
+		return requireAdmin(trusted, next)
+	})
+
+	ad.Path("/metrics").
+		Methods("GET").
+		Handler(promhttp.Handler())
+
+	ad.Path("/log-level").
+		Methods("GET", "POST").
+		HandlerFunc(handleAdminLogLevel)
+
+	ad.Path("/goroutines").
+		Methods("GET").
+		HandlerFunc(handleAdminGoroutines)
+
+	ad.Path("/drain").
+		Methods("GET", "POST").
+		HandlerFunc(handleAdminDrain)
+
+	ad.Path("/webhooks/deliveries").
+		Methods("GET").
+		HandlerFunc(handleAdminWebhookDeliveries)
+
+	ad.Path("/sessions/{session}/expire").
+		Methods("POST").
+		HandlerFunc(handleAdminExpireSession)
+
+	// The pprof handlers expect to be served below /debug/pprof/
+	ad.PathPrefix("/debug/pprof/").
+		Handler(http.StripPrefix("/admin", http.HandlerFunc(handleAdminPprof)))
+
+	// Metrics are only public on the public listeners if explicitly requested
+	metrics := promhttp.Handler()
+	if !metricsPublic || trusted {
+		metrics = requireAdmin(trusted, metrics)
+	}
+
+	r.Path("/metrics").
+		Methods("GET").
+		Handler(metrics)
+}
+
+func handleAdminPprof(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(w, r)
+	case "/debug/pprof/profile":
+		pprof.Profile(w, r)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(w, r)
+	case "/debug/pprof/trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// handleAdminGoroutines returns the stacks of all goroutines as text or their count as JSON.
+func handleAdminGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, &apiGoroutinesResponse{
+			Count: runtime.NumGoroutine(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		subsystemLogger("api").Error("Failed to write goroutine dump", slog.Any("error", err))
+	}
+}
+
+// handleAdminLogLevel returns or changes the global or per-subsystem log levels.
+func handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "POST" {
+		q := r.URL.Query()
+
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(q.Get("level"))); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid log level: %w", err))
+			return
+		}
+
+		subsystem := q.Get("subsystem")
+
+		logSettings.SetLevel(subsystem, lvl)
+		audit(r, AuditLogLevel, "", "", nil)
+
+		slog.Info("Changed log level",
+			slog.String("target_subsystem", subsystem),
+			slog.String("level", lvl.String()))
+	}
+
+	global, subsystems := logSettings.Levels()
+
+	resp := &apiLogLevelResponse{
+		Level:      global.String(),
+		Subsystems: map[string]string{},
+	}
+
+	for name, lvl := range subsystems {
+		resp.Subsystems[name] = lvl.String()
+	}
+
+	writeJSON(w, resp)
+}
+
+// handleAdminWebhookDeliveries returns the most recent webhook deliveries.
+func handleAdminWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	writeJSON(w, &apiWebhookDeliveriesResponse{
+		Deliveries: webhookDeliveries.List(),
+	})
+}
+
+// handleAdminExpireSession expires a session immediately and disconnects its peers.
+func handleAdminExpireSession(w http.ResponseWriter, r *http.Request) {
+	sessName := mux.Vars(r)["session"]
+
+	err := ExpireSession(sessName)
+	audit(r, AuditSessionExpire, sessName, "", err)
+	if errors.Is(err, errSessionNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to expire session: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
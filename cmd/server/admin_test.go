@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMetricsAccess checks that metrics on the public listeners
+// require an admin token unless they have been made public explicitly.
+func TestMetricsAccess(t *testing.T) {
+	t.Cleanup(func() {
+		adminTokens = nil
+		metricsPublic = false
+	})
+
+	get := func(trusted bool, token string) int {
+		r := mux.NewRouter()
+		addAdminRoutes(r, trusted)
+
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		name    string
+		tokens  []string
+		public  bool
+		trusted bool
+		token   string
+		want    int
+	}{
+		{"no tokens", nil, false, false, "", http.StatusForbidden},
+		{"public", nil, true, false, "", http.StatusOK},
+		{"admin listener", nil, false, true, "", http.StatusOK},
+		{"missing token", []string{"t0ken"}, false, false, "", http.StatusUnauthorized},
+		{"wrong token", []string{"t0ken"}, false, false, "wrong", http.StatusUnauthorized},
+		{"token", []string{"t0ken"}, false, false, "t0ken", http.StatusOK},
+	} {
+		adminTokens = tc.tokens
+		metricsPublic = tc.public
+
+		if got := get(tc.trusted, tc.token); got != tc.want {
+			t.Errorf("%s: unexpected status: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestAdminRoutesAccess checks that the drain and webhook delivery endpoints
+// are protected like the rest of the admin API.
+func TestAdminRoutesAccess(t *testing.T) {
+	t.Cleanup(func() {
+		adminTokens = nil
+	})
+
+	for _, path := range []string{"/admin/drain", "/admin/webhooks/deliveries"} {
+		for _, tc := range []struct {
+			name    string
+			tokens  []string
+			trusted bool
+			token   string
+			want    int
+		}{
+			{"no tokens", nil, false, "", http.StatusForbidden},
+			{"admin listener", nil, true, "", http.StatusOK},
+			{"missing token", []string{"t0ken"}, false, "", http.StatusUnauthorized},
+			{"wrong token", []string{"t0ken"}, true, "wrong", http.StatusUnauthorized},
+			{"token", []string{"t0ken"}, false, "t0ken", http.StatusOK},
+		} {
+			adminTokens = tc.tokens
+
+			r := mux.NewRouter()
+			addAdminRoutes(r, tc.trusted)
+
+			req := httptest.NewRequest("GET", path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Errorf("%s %s: unexpected status: got %d, want %d", path, tc.name, rec.Code, tc.want)
+			}
+		}
+	}
+}
+
+// TestAdminLogLevel checks that the changed subsystem is not logged
+// with the key which denotes the subsystem of the logger itself.
+func TestAdminLogLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	logger, settings := slog.Default(), logSettings
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		logSettings = settings
+	})
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+	logSettings = &logConfig{
+		subsystems: map[string]*slog.LevelVar{},
+	}
+
+	r := mux.NewRouter()
+	addAdminRoutes(r, true)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/admin/log-level?subsystem=webhook&level=debug", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", rec.Code)
+	}
+
+	resp := &apiLogLevelResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+		t.Fatalf("Failed to decode response: %s", err)
+	}
+
+	if resp.Subsystems["webhook"] != "DEBUG" {
+		t.Errorf("Unexpected log levels: %+v", resp)
+	}
+
+	rl := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &rl); err != nil {
+		t.Fatalf("Failed to parse log record: %s", err)
+	}
+
+	if _, ok := rl[subsystemKey]; ok || rl["target_subsystem"] != "webhook" {
+		t.Errorf("Unexpected log record: %s", buf)
+	}
+}
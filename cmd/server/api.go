@@ -4,10 +4,13 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/VILLASframework/signaling/pkg"
 	"github.com/gorilla/mux"
@@ -22,6 +25,15 @@ type apiSessionsResponse struct {
 	Sessions []pkg.Session `json:"sessions"`
 }
 
+type apiSessionRequest struct {
+	Session *struct {
+		Protected *bool             `json:"protected"`
+		Labels    map[string]string `json:"labels"`
+		Record    *bool             `json:"record"`
+		TTL       *string           `json:"ttl"`
+	} `json:"session"`
+}
+
 type apiSessionResponse struct {
 	Session pkg.Session `json:"session"`
 }
@@ -39,8 +51,14 @@ type apiPeerResponse struct {
 func handleAPISessions(w http.ResponseWriter, r *http.Request) {
 	resp := &apiSessionsResponse{}
 
+	id := identity(r)
+
 	ss := []pkg.Session{}
-	for _, s := range sessions {
+	for _, s := range GetSessions() {
+		if id != nil && !id.CanAccessSession(s.Name) {
+			continue
+		}
+
 		ss = append(ss, s.Marshal())
 	}
 
@@ -54,18 +72,88 @@ func handleAPISession(w http.ResponseWriter, r *http.Request) {
 	sessName := vars["session"]
 
 	// Create session if this is post request
-	sess, ok := sessions[sessName]
-	if !ok {
-		if r.Method == "POST" {
-			sess = NewSession(sessName)
-
-			sessionsMutex.Lock()
-			sessions[sessName] = sess
-			sessionsMutex.Unlock()
-		} else {
+	var sess *Session
+	if r.Method == "POST" {
+		var err error
+		sess, err = GetOrCreateSession(sessName)
+		audit(r, AuditSessionCreate, sessName, "", err)
+		if errors.Is(err, errDraining) {
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to create new session: %w", err))
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create new session: %w", err))
+			return
+		}
+
+		// The request body is optional
+		req := &apiSessionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to parse request body: %w", err))
+			return
+		}
+
+		if req.Session != nil && req.Session.Protected != nil {
+			err := sess.SetProtected(*req.Session.Protected)
+			audit(r, AuditSessionUpdate, sessName, "", err)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to update session: %w", err))
+				return
+			}
+		}
+
+		if req.Session != nil && req.Session.Record != nil {
+			if recordDir == "" {
+				writeError(w, http.StatusNotImplemented, errors.New("recording is not enabled"))
+				return
+			}
+
+			err := sess.SetRecording(*req.Session.Record)
+			audit(r, AuditSessionUpdate, sessName, "", err)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to update session: %w", err))
+				return
+			}
+		}
+
+		if req.Session != nil && req.Session.TTL != nil {
+			ttl, err := time.ParseDuration(*req.Session.TTL)
+			if err != nil || ttl < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", *req.Session.TTL))
+				return
+			}
+
+			err = sess.SetTTL(ttl)
+			audit(r, AuditSessionUpdate, sessName, "", err)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to update session: %w", err))
+				return
+			}
+		}
+
+		if req.Session != nil && req.Session.Labels != nil {
+			err := sess.SetLabels(req.Session.Labels)
+			audit(r, AuditSessionUpdate, sessName, "", err)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to update session: %w", err))
+				return
+			}
+		}
+	} else if r.Method == "DELETE" {
+		err := DeleteSession(sessName)
+		audit(r, AuditSessionDelete, sessName, "", err)
+		if errors.Is(err, errSessionNotFound) {
 			writeError(w, http.StatusNotFound, fmt.Errorf("failed to find session with name '%s'", sessName))
 			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to delete session: %w", err))
+			return
 		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if sess = GetSession(sessName); sess == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("failed to find session with name '%s'", sessName))
+		return
 	}
 
 	resp := &apiSessionResponse{
@@ -88,12 +176,20 @@ func handleAPIPeer(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "POST":
 		sess, err = GetOrCreateSession(sessName)
-		if err != nil {
+		if errors.Is(err, errDraining) {
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to create new session: %w", err))
+			return
+		} else if err != nil {
 			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create new session: %w", err))
 			return
 		}
 
+		existing := sess.GetPeer(peerName) != nil
+
 		peer, err = sess.GetOrCreatePeer(peerName)
+		if !existing {
+			audit(r, AuditPeerRegister, sessName, peerName, err)
+		}
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create new peer: %w", err))
 			return
@@ -126,15 +222,20 @@ func handleAPIPeer(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if sigs := req.Peer.Signals; sigs != nil {
-			peer.logger.Debug("Updated signals", slog.Any("signals", sigs))
+			err := peer.SetSignals(sigs)
+			audit(r, AuditSignalsUpdate, sessName, peerName, err)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to update signals: %w", err))
+				return
+			}
 
-			peer.mutex.Lock()
-			peer.signals = sigs
-			peer.mutex.Unlock()
+			peer.logger.Debug("Updated signals", slog.Any("signals", sigs))
 		}
 
 	case "DELETE":
-		if err := sess.RemovePeer(peer); err != nil {
+		err := sess.RemovePeer(peer)
+		audit(r, AuditPeerDelete, sessName, peerName, err)
+		if err != nil {
 			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to remove peer: %w", err))
 			return
 		}
@@ -12,7 +12,7 @@ import (
 
 func writeJSON(w http.ResponseWriter, resp any) bool {
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		slog.Error("Failed to encode API response",
+		subsystemLogger("api").Error("Failed to encode API response",
 			slog.Any("error", err),
 			slog.Any("resp", resp))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -38,13 +38,9 @@ func writeError(w http.ResponseWriter, code int, err error) bool {
 		Status: http.StatusText(code),
 	}
 
-	slog.Error("Request failed", slog.Any("error", err))
+	subsystemLogger("api").Error("Request failed", slog.Any("error", err))
 
-	if writeJSON(w, resp) {
-		w.WriteHeader(code)
+	w.WriteHeader(code)
 
-		return true
-	}
-
-	return false
+	return writeJSON(w, resp)
 }
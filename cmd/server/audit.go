@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	AuditSessionCreate   = "session.create"
+	AuditSessionUpdate   = "session.update"
+	AuditSessionDelete   = "session.delete"
+	AuditSessionExpire   = "session.expire"
+	AuditPeerRegister    = "peer.register"
+	AuditPeerDelete      = "peer.delete"
+	AuditSignalsUpdate   = "peer.signals_update"
+	AuditJoinURLCreate   = "join_url.create"
+	AuditTURNCredentials = "turn.credentials"
+	AuditRelayCreate     = "relay.create"
+	AuditRelayUpdate     = "relay.update"
+	AuditRelayDelete     = "relay.delete"
+	AuditServerDrain     = "server.drain"
+	AuditLogLevel        = "server.log_level"
+	AuditAuthFailure     = "auth.failure"
+)
+
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Principal string    `json:"principal"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Session   string    `json:"session,omitempty"`
+	Peer      string    `json:"peer,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// auditLog appends records as JSON lines to a file and/or syslog.
+type auditLog struct {
+	writers []io.Writer
+	mutex   sync.Mutex
+}
+
+var (
+	// Flags
+	auditLogPath string
+	auditSyslog  string
+
+	auditor = &auditLog{}
+)
+
+// setupAuditLog opens the configured audit log destinations.
+// The syslog destination is either "local" or a URL like udp://host:514.
+func setupAuditLog() error {
+	if auditLogPath != "" {
+		f, err := os.OpenFile(auditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+
+		auditor.writers = append(auditor.writers, f)
+	}
+
+	if auditSyslog != "" {
+		var network, raddr string
+		if auditSyslog != "local" {
+			u, err := url.Parse(auditSyslog)
+			if err != nil {
+				return fmt.Errorf("invalid syslog address: %w", err)
+			}
+
+			network, raddr = u.Scheme, u.Host
+		}
+
+		w, err := syslog.Dial(network, raddr, syslog.LOG_NOTICE|syslog.LOG_AUTH, "villas-signaling")
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+
+		auditor.writers = append(auditor.writers, w)
+	}
+
+	return nil
+}
+
+func (l *auditLog) Write(rec AuditRecord) {
+	if len(l.writers) == 0 {
+		return
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("Failed to encode audit record", slog.Any("error", err))
+		return
+	}
+
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, w := range l.writers {
+		if _, err := w.Write(line); err != nil {
+			slog.Error("Failed to write audit record", slog.Any("error", err))
+		}
+	}
+}
+
+// audit records an action performed via the API.
+func audit(r *http.Request, action, sess, peer string, err error) {
+	rec := AuditRecord{
+		Time:      time.Now(),
+		Action:    action,
+		Principal: principal(r),
+		Remote:    r.RemoteAddr,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Session:   sess,
+		Peer:      peer,
+		Success:   err == nil,
+	}
+
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	auditor.Write(rec)
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// setupTestKeyStore configures API key authentication with a single key.
+// It returns the secret of the key.
+func setupTestKeyStore(t *testing.T, name string, scopes []Scope, prefixes []string, ttl time.Duration) (*KeyStore, string) {
+	t.Helper()
+
+	ks, err := NewKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("Failed to create key store: %s", err)
+	}
+
+	_, secret, err := ks.Create(name, scopes, prefixes, ttl)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+
+	authenticators = []Authenticator{ks}
+	t.Cleanup(func() {
+		authenticators = nil
+	})
+
+	return ks, secret
+}
+
+// TestAuditLog checks the records written to the audit log file and syslog
+// for successful actions and failed authentications.
+func TestAuditLog(t *testing.T) {
+	syslogConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer syslogConn.Close()
+
+	auditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	auditSyslog = "udp://" + syslogConn.LocalAddr().String()
+	t.Cleanup(func() {
+		auditLogPath = ""
+		auditSyslog = ""
+		auditor = &auditLog{}
+	})
+
+	if err := setupAuditLog(); err != nil {
+		t.Fatalf("Failed to setup audit log: %s", err)
+	}
+
+	_, secret := setupTestKeyStore(t, "operator", []Scope{ScopeSessionsWrite}, nil, 0)
+
+	r := mux.NewRouter()
+	r.Path("/session/{session}").
+		HandlerFunc(requireScope(ScopeSessionsWrite, func(w http.ResponseWriter, r *http.Request) {
+			audit(r, AuditSessionDelete, mux.Vars(r)["session"], "", nil)
+		}))
+
+	for _, token := range []string{secret, "vsk_00000000_invalid"} {
+		req := httptest.NewRequest("DELETE", "/session/test", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	f, err := os.Open(auditLogPath)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %s", err)
+	}
+	defer f.Close()
+
+	recs := []AuditRecord{}
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		if strings.Contains(scanner.Text(), "vsk_") {
+			t.Errorf("Audit log contains a token: %s", scanner.Text())
+		}
+
+		rec := AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Failed to parse audit record: %s", err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("Unexpected number of audit records: %d", len(recs))
+	}
+
+	for i, want := range []AuditRecord{
+		{
+			Action:    AuditSessionDelete,
+			Principal: "operator",
+			Remote:    "192.0.2.1:1234",
+			Method:    "DELETE",
+			Path:      "/session/test",
+			Session:   "test",
+			Success:   true,
+		},
+		{
+			Action:    AuditAuthFailure,
+			Principal: "anonymous",
+			Remote:    "192.0.2.1:1234",
+			Method:    "DELETE",
+			Path:      "/session/test",
+			Error:     errUnknownCredentials.Error(),
+		},
+	} {
+		got := recs[i]
+		if got.Time.IsZero() || time.Since(got.Time) > time.Minute {
+			t.Errorf("Unexpected time of record %d: %s", i, got.Time)
+		}
+
+		got.Time = time.Time{}
+		if got != want {
+			t.Errorf("Unexpected record %d:\ngot  %+v\nwant %+v", i, got, want)
+		}
+	}
+
+	// The same records are sent to syslog
+	buf := make([]byte, 4096)
+	for _, action := range []string{AuditSessionDelete, AuditAuthFailure} {
+		syslogConn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+		n, _, err := syslogConn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to receive syslog message: %s", err)
+		}
+
+		if msg := string(buf[:n]); !strings.Contains(msg, `"action":"`+action+`"`) || !strings.Contains(msg, "villas-signaling") {
+			t.Errorf("Unexpected syslog message: %s", msg)
+		}
+	}
+}
@@ -4,39 +4,158 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type Scope string
+
+const (
+	ScopeSessionsRead  Scope = "sessions:read"
+	ScopeSessionsWrite Scope = "sessions:write"
+	ScopePeersWrite    Scope = "peers:write"
+	ScopeRelaysRead    Scope = "relays:read"
+	ScopeRelaysWrite   Scope = "relays:write"
+	ScopeAdmin         Scope = "admin"
 )
 
 var (
-	authUsername = flag.String("api-username", "admin", "Username for API endpoint")
-	authPassword = flag.String("api-password", "", "Password for API endpoint")
-	authToken    = flag.String("api-token", "", "Bearer token for authentication")
+	errNoCredentials      = errors.New("missing credentials")
+	errUnknownCredentials = errors.New("unknown credentials")
 )
 
-func basicAuth(next http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		valid := true
-		if *authPassword != "" {
-			username, password, ok := r.BasicAuth()
-			valid = ok && username == *authUsername && password == *authPassword
-		} else if *authToken != "" {
-			authHeader := r.Header.Get("Authentication")
-			if authHeader != "" {
-				tokens := strings.Split(authHeader, " ")
-				switch tokens[0] {
-				case "Bearer":
-					valid = tokens[1] == *authToken
-				}
-			}
+// Identity describes an authenticated API client and its permissions.
+type Identity struct {
+	Name   string
+	Scopes []Scope
+
+	// Restricts access to sessions whose names start with one of the prefixes.
+	Prefixes []string
+}
+
+func (id *Identity) HasScope(scope Scope) bool {
+	return slices.Contains(id.Scopes, scope) || slices.Contains(id.Scopes, ScopeAdmin)
+}
+
+func (id *Identity) CanAccessSession(name string) bool {
+	if len(id.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range id.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator validates bearer tokens.
+// It returns errUnknownCredentials if the token is not handled by it.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+var authenticators []Authenticator
+
+type identityKey struct{}
+
+// withIdentity attaches the authenticated client to the request.
+func withIdentity(r *http.Request, id *Identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+}
+
+// identity returns the authenticated client of a request or nil if authentication is disabled.
+func identity(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// principal returns the name of the authenticated client of a request.
+func principal(r *http.Request) string {
+	if id := identity(r); id != nil {
+		return id.Name
+	}
+
+	return "anonymous"
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", errNoCredentials
+	}
+
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errors.New("unsupported authorization scheme")
+	}
+
+	return token, nil
+}
+
+func authenticate(r *http.Request) (*Identity, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range authenticators {
+		id, err := a.Authenticate(r.Context(), token)
+		if errors.Is(err, errUnknownCredentials) {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
 
-		if valid {
+		return id, nil
+	}
+
+	return nil, errUnknownCredentials
+}
+
+// requireScope only passes requests to next which are authenticated and carry the given scope.
+// If the route has a session variable, access to the session is checked as well.
+// Authentication is disabled if no authenticators are configured.
+func requireScope(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(authenticators) == 0 {
 			next.ServeHTTP(w, r)
-		} else {
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := authenticate(r)
+		if err != nil {
+			audit(r, AuditAuthFailure, "", "", err)
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="villas-signaling"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized: %w", err))
+			return
+		}
+
+		r = withIdentity(r, id)
+
+		if !id.HasScope(scope) {
+			err := fmt.Errorf("missing scope '%s'", scope)
+			audit(r, AuditAuthFailure, "", "", err)
+			writeError(w, http.StatusForbidden, err)
+			return
 		}
-	})
+
+		if sess, ok := mux.Vars(r)["session"]; ok && !id.CanAccessSession(sess) {
+			err := fmt.Errorf("no access to session '%s'", sess)
+			audit(r, AuditAuthFailure, sess, "", err)
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
 }
@@ -4,35 +4,63 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VILLASframework/signaling/pkg"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Connection owns the WebSocket connection of a peer.
+// All writes to the WebSocket are performed by the run() goroutine,
+// all reads by the read() goroutine.
 type Connection struct {
 	*websocket.Conn
 
-	peer *Peer
+	peer      *Peer
+	userAgent string
+
+	// The peer joined via a signed join URL
+	signedJoin bool
 
 	messages chan SignalingMessage
-	closing  bool
-	close    chan struct{}
-	done     chan struct{}
+	reason   string
+
+	// Reason for aborting the connection without a closing handshake
+	abortReason atomic.Pointer[string]
+
+	// Initial signals message received during the handshake
+	signals *SignalingMessage
+
+	// Selected relays and the time at which their credentials need to be refreshed
+	relaySelection relaySelection
+	relays         []pkg.RelayInfo
+	relaysRelease  func()
+	relaysReleased bool
+	relaysMutex    sync.Mutex
+	relaysUpdate   chan struct{}
+	relaysRefresh  time.Time
+
+	closing   atomic.Bool
+	close     chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
 
 	logger *slog.Logger
 }
 
 func (p *Peer) Connect(w http.ResponseWriter, r *http.Request) error {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	if p.conn != nil {
-		return errors.New("peer is already connected")
+	if p.IsConnected() {
+		return errPeerConnected
 	}
 
 	wsConn, err := upgrader.Upgrade(w, r, nil)
@@ -40,78 +68,244 @@ func (p *Peer) Connect(w http.ResponseWriter, r *http.Request) error {
 		return fmt.Errorf("failed to upgrade connection: %w", err)
 	}
 
-	p.id = p.session.lastPeerID.Add(1)
-	p.userAgent = r.UserAgent()
-	p.connected = time.Now()
-	p.conn = &Connection{
-		Conn:     wsConn,
-		peer:     p,
-		messages: make(chan SignalingMessage),
-		close:    make(chan struct{}),
-		done:     make(chan struct{}),
-		logger:   p.logger.With(slog.String("remote", r.RemoteAddr)),
+	c := &Connection{
+		Conn:       wsConn,
+		peer:       p,
+		userAgent:  r.UserAgent(),
+		signedJoin: hasSignedJoin(r, p.session, p.Name),
+		messages:   make(chan SignalingMessage, 100),
+		relaySelection: relaySelection{
+			Remote: remoteIP(r.RemoteAddr),
+			Hint:   r.URL.Query().Get("relay-hint"),
+			Labels: p.session.Labels(),
+		},
+		relaysUpdate: make(chan struct{}, 1),
+		close:        make(chan struct{}),
+		done:         make(chan struct{}),
+		logger: subsystemLogger("connection").With(
+			slog.String("session", p.session.Name),
+			slog.String("peer", p.Name),
+			slog.String("remote", r.RemoteAddr)),
+	}
+
+	c.selectRelays()
+
+	if err := c.handshake(); err != nil {
+		c.releaseRelays()
+		c.Conn.Close() //nolint:errcheck
+		return err
 	}
 
-	p.conn.SetReadLimit(maxMessageSize)
-	if err := p.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+	go c.run()
+
+	if err := p.session.attach(p, c); err != nil {
+		c.releaseRelays()
+		c.Conn.Close() //nolint:errcheck
+		close(c.done)
+		return fmt.Errorf("failed to attach connection: %w", err)
+	}
+
+	go c.read()
+
+	return nil
+}
+
+// handshake performs the initial exchange of signals and relays.
+// It is executed before the read() and run() goroutines are started.
+func (c *Connection) handshake() error {
+	c.SetReadLimit(maxMessageSize)
+	if err := c.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		return fmt.Errorf("failed to set read deadline: %w", err)
 	}
 
-	p.conn.SetPongHandler(func(string) error {
-		return p.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(pongWait))
 	})
 
-	if err := p.conn.RecvSignalsMessage(); err != nil {
+	if err := c.RecvSignalsMessage(); err != nil {
 		return fmt.Errorf("failed to receive signals message: %w", err)
 	}
 
-	if err := p.conn.SendRelaysMessage(); err != nil {
+	if err := c.SendRelaysMessage(); err != nil {
 		return fmt.Errorf("failed to send relays message: %w", err)
 	}
 
-	if err := p.session.SendControlMessageToAllConnectedPeers(); err != nil {
-		return fmt.Errorf("failed to send control messages: %w", err)
+	return nil
+}
+
+// selectRelays (re-)selects the relays for the connection from all enabled relays.
+func (c *Connection) selectRelays() {
+	rs, release := selectRelays(relayStore.Relays(), c.relaySelection)
+
+	c.relaysMutex.Lock()
+	defer c.relaysMutex.Unlock()
+
+	if c.relaysReleased {
+		release()
+		return
 	}
 
-	go p.conn.read()
-	go p.conn.run()
+	if c.relaysRelease != nil {
+		c.relaysRelease()
+	}
 
-	return nil
+	c.relays, c.relaysRelease = rs, release
 }
 
-func (c *Connection) Close() error {
-	if c.closing {
-		return errors.New("connection is closing")
+// releaseRelays releases the selected relays once the connection is closed.
+func (c *Connection) releaseRelays() {
+	c.relaysMutex.Lock()
+	defer c.relaysMutex.Unlock()
+
+	if c.relaysRelease != nil {
+		c.relaysRelease()
+	}
+
+	c.relays, c.relaysRelease, c.relaysReleased = nil, nil, true
+}
+
+// Relays returns the URLs of the relays selected for the connection.
+func (c *Connection) Relays() []string {
+	c.relaysMutex.Lock()
+	defer c.relaysMutex.Unlock()
+
+	urls := []string{}
+	for _, ri := range c.relays {
+		urls = append(urls, ri.URL)
+	}
+
+	return urls
+}
+
+// UpdateRelays requests the run() goroutine to re-select the relays and send them to the peer.
+func (c *Connection) UpdateRelays() {
+	select {
+	case c.relaysUpdate <- struct{}{}:
+	default:
 	}
+}
 
-	c.closing = true
-	c.logger.Info("Connection closing")
+// Send queues a message for transmission to the peer.
+// It never blocks so that a slow peer can not stall its session.
+// Instead, peers which do not keep up with their messages are disconnected.
+// It returns false if the message has not been queued.
+func (c *Connection) Send(msg SignalingMessage) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
 
-	if err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-		return fmt.Errorf("failed to send close message: %w", err)
+	select {
+	case c.messages <- msg:
+		return true
+	default:
+		c.abort("slow_consumer", errors.New("send buffer is full"))
+		return false
 	}
+}
+
+// abort terminates the connection immediately without a closing handshake.
+func (c *Connection) abort(reason string, err error) {
+	if !c.abortReason.CompareAndSwap(nil, &reason) {
+		return
+	}
+
+	c.closing.Store(true)
+
+	c.logger.Warn("Disconnecting peer",
+		slog.String("reason", reason),
+		slog.Any("error", err))
+
+	// Unblocks the read() goroutine which then cleans up the connection
+	if err := c.Conn.Close(); err != nil {
+		c.logger.Error("Failed to close connection", slog.Any("error", err))
+	}
+}
+
+// Close initiates the closing handshake and waits until the connection is closed.
+func (c *Connection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.close)
+	})
 
 	select {
 	case <-c.done:
 	case <-time.After(time.Second):
 		c.logger.Warn("Timed-out waiting for connection close")
+
+		// Unblock the read() goroutine
+		if err := c.Conn.Close(); err != nil {
+			return fmt.Errorf("failed to close connection: %w", err)
+		}
+
+		<-c.done
+	}
+
+	return nil
+}
+
+// readMessage reads and decodes the next signaling message and collects metrics about it.
+func (c *Connection) readMessage(msg *pkg.SignalingMessage) error {
+	_, buf, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(buf, msg); err != nil {
+		return fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	c.collectMetrics("rx", msg, len(buf))
+
+	return nil
+}
+
+// writeMessage encodes and writes a signaling message and collects metrics about it.
+// It must only be called by the run() goroutine or during the handshake.
+func (c *Connection) writeMessage(msg *pkg.SignalingMessage) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
 	}
 
+	if err := c.WriteMessage(websocket.TextMessage, buf); err != nil {
+		return err
+	}
+
+	c.collectMetrics("tx", msg, len(buf))
+
 	return nil
 }
 
+func (c *Connection) collectMetrics(dir string, msg *pkg.SignalingMessage, size int) {
+	typ := messageType(msg)
+	sess := sessionLabel(c.peer.session.Name)
+	peer := peerLabel(c.peer.Name)
+
+	metricMessagesForwarded.WithLabelValues(sess, peer, dir, typ).Inc()
+	metricBytesForwarded.WithLabelValues(sess, peer, dir, typ).Add(float64(size))
+	metricMessageSize.WithLabelValues(dir, typ).Observe(float64(size))
+}
+
 func (c *Connection) RecvSignalsMessage() error {
 	msg := &pkg.SignalingMessage{}
 
-	if err := c.ReadJSON(msg); err != nil {
+	if err := c.readMessage(msg); err != nil {
 		return fmt.Errorf("failed to read signaling message: %w", err)
 	}
 
+	c.signals = &SignalingMessage{
+		SignalingMessage: *msg,
+		Sender:           c.peer,
+		Received:         time.Now(),
+	}
+
 	// TODO: Wait until we get valid signals from node
 	if false && msg.Signals != nil {
-		c.peer.mutex.Lock()
-		c.peer.signals = msg.Signals
-		c.peer.mutex.Unlock()
+		if err := c.peer.SetSignals(msg.Signals); err != nil {
+			return err
+		}
 
 		c.logger.Debug("Received signals", slog.Any("signals", msg.Signals))
 	}
@@ -120,44 +314,63 @@ func (c *Connection) RecvSignalsMessage() error {
 }
 
 func (c *Connection) SendRelaysMessage() error {
-	msg := &pkg.SignalingMessage{}
+	c.relaysMutex.Lock()
+	infos := c.relays
+	c.relaysMutex.Unlock()
+
+	rs, exp := getRelays(infos, relayUsername(c.peer))
 
-	for _, relay := range relays {
-		user, pass, exp := relay.GetCredentials("villas")
-		msg.Relays = append(msg.Relays, pkg.Relay{
-			URL:      relay.URL,
-			Username: user,
-			Password: pass,
-			Realm:    relay.Realm,
-			Expires:  exp.Format(time.RFC3339),
-		})
+	msg := &pkg.SignalingMessage{
+		Relays: rs,
 	}
 
-	return c.WriteJSON(msg)
+	if exp.IsZero() {
+		c.relaysRefresh = time.Time{}
+	} else {
+		c.relaysRefresh = relayRefreshTime(exp)
+	}
+
+	return c.writeMessage(msg)
 }
 
 func (c *Connection) handleMessage(msg pkg.SignalingMessage) {
-	c.logger.Info("Received signaling message", slog.Any("msg", msg))
+	c.logger.Debug("Received signaling message", logMessage(&msg))
 
-	c.peer.session.messages <- SignalingMessage{
+	if err := c.peer.session.Post(SignalingMessage{
 		SignalingMessage: msg,
 		Sender:           c.peer,
+		Received:         time.Now(),
+	}); err != nil {
+		c.logger.Error("Failed to forward message", slog.Any("error", err))
 	}
 }
 
 func (c *Connection) read() {
 	for {
 		msg := pkg.SignalingMessage{}
-		if err := c.ReadJSON(&msg); err != nil {
-			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				if !c.closing {
-					c.closing = true
+		if err := c.readMessage(&msg); err != nil {
+			var netErr net.Error
+
+			if reason := c.abortReason.Load(); reason != nil {
+				c.reason = *reason
+			} else if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				if !c.closing.Swap(true) {
+					c.reason = "closed_by_peer"
+
 					err := c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(5*time.Second))
 					if err != nil && err != websocket.ErrCloseSent {
 						c.logger.Error("Failed to send close message", slog.Any("error", err))
 					}
+				} else {
+					c.reason = "closed_by_server"
 				}
+			} else if c.closing.Load() {
+				c.reason = "closed_by_server"
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				c.reason = "timeout"
+				c.logger.Error("Failed to read", slog.Any("error", err))
 			} else {
+				c.reason = "error"
 				c.logger.Error("Failed to read", slog.Any("error", err))
 			}
 			break
@@ -171,66 +384,133 @@ func (c *Connection) read() {
 
 func (c *Connection) run() {
 	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	closeRequested := c.close
+
+	refresh := time.NewTimer(0)
+	refresh.Stop()
+	defer refresh.Stop()
+
+	scheduleRefresh := func() {
+		if !refresh.Stop() {
+			select {
+			case <-refresh.C:
+			default:
+			}
+		}
+
+		if !c.relaysRefresh.IsZero() {
+			refresh.Reset(time.Until(c.relaysRefresh))
+		}
+	}
+
+	scheduleRefresh()
 
-loop:
 	for {
 		select {
-
 		case <-c.done:
-			break loop
+			return
+
+		case <-closeRequested:
+			// Only handle the close request once
+			closeRequested = nil
 
-		case msg, ok := <-c.messages:
-			if !ok {
-				c.Close()
-				break loop
+			if c.closing.Swap(true) {
+				continue
 			}
 
-			c.logger.Info("Sending signaling message",
+			c.logger.Info("Connection closing")
+
+			if err := c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait)); err != nil && err != websocket.ErrCloseSent {
+				c.logger.Error("Failed to send close message", slog.Any("error", err))
+			}
+
+		case msg := <-c.messages:
+			c.logger.Debug("Sending signaling message",
 				slog.Any("from", msg.Sender),
-				slog.Any("msg", msg))
+				logMessage(&msg.SignalingMessage))
 
 			if err := c.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				c.logger.Error("Failed to set read deadline", slog.Any("error", err))
+				c.logger.Error("Failed to set write deadline", slog.Any("error", err))
 			}
 
-			if err := c.WriteJSON(msg.SignalingMessage); err != nil {
+			var span trace.Span
+			if msg.ctx != nil {
+				_, span = tracer.Start(msg.ctx, "send "+messageType(&msg.SignalingMessage),
+					trace.WithSpanKind(trace.SpanKindClient),
+					sessionAttributes(c.peer.session.Name, c.peer.Name))
+			}
+
+			if err := c.writeMessage(&msg.SignalingMessage); err != nil {
 				c.logger.Error("Failed to send message", slog.Any("error", err))
+
+				if span != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "failed to send message")
+				}
+			} else if !msg.Received.IsZero() {
+				metricForwardLatency.WithLabelValues(sessionLabel(c.peer.session.Name)).Observe(time.Since(msg.Received).Seconds())
 			}
 
-		case <-ticker.C:
-			c.logger.Debug("Send ping message")
+			if span != nil {
+				span.End()
+			}
+
+		case <-refresh.C:
+			c.logger.Debug("Refreshing relay credentials")
 
 			if err := c.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
 				c.logger.Error("Failed to set write deadline", slog.Any("error", err))
 			}
 
-			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+			if err := c.SendRelaysMessage(); err != nil {
+				c.logger.Error("Failed to send relays message", slog.Any("error", err))
+			}
+
+			scheduleRefresh()
+
+		case <-c.relaysUpdate:
+			c.logger.Info("Updating relays")
+
+			c.selectRelays()
+
+			if err := c.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				c.logger.Error("Failed to set write deadline", slog.Any("error", err))
+			}
+
+			if err := c.SendRelaysMessage(); err != nil {
+				c.logger.Error("Failed to send relays message", slog.Any("error", err))
+			}
+
+			scheduleRefresh()
+
+		case <-ticker.C:
+			c.logger.Debug("Send ping message")
+
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
 				c.logger.Error("Failed to ping", slog.Any("error", err))
 			}
 		}
 	}
 }
 
+// closed is called by the read() goroutine once the connection has been terminated.
 func (c *Connection) closed() {
-	if err := c.Conn.Close(); err != nil {
+	if err := c.Conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 		c.logger.Error("Failed to close connection", slog.Any("error", err))
 	}
 
 	c.logger.Info("Connection closed")
 
-	c.peer.conn = nil
-	c.peer.connected = time.Time{}
+	c.releaseRelays()
 
-	if err := c.peer.session.SendControlMessageToAllConnectedPeers(); err != nil {
-		c.logger.Error("Failed to send control message", slog.Any("error", err))
-	}
+	// Signal completion before detaching from the session as the session
+	// goroutine might be waiting for us in Close()
+	close(c.done)
 
-	// Remove peer if it does not have any signal metadata associated
-	if c.peer.signals == nil {
-		if err := c.peer.session.RemovePeer(c.peer); err != nil {
-			c.logger.Error("Failed to close peer", slog.Any("error", err))
-		}
+	// Connections of closed sessions are accounted by the session itself
+	if err := c.peer.session.detach(c.peer, c); err != nil && !errors.Is(err, errSessionClosed) {
+		c.logger.Error("Failed to detach connection", slog.Any("error", err))
 	}
-
-	close(c.done)
 }
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const corsMaxAge = 600 // seconds
+
+var (
+	corsAllowedMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", "Traceparent", "Tracestate"}
+)
+
+type origins []string
+
+func (o *origins) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *origins) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+var (
+	// Flags
+	allowedOrigins  origins
+	corsCredentials bool
+)
+
+// checkCORSConfig rejects allowing credentials for any origin
+// as this would allow every website to access the API with the credentials of its visitors.
+func checkCORSConfig() error {
+	if corsCredentials && slices.Contains(allowedOrigins, "*") {
+		return errors.New("-cors-credentials can not be combined with -allowed-origin '*'")
+	}
+
+	return nil
+}
+
+// originAllowed checks an origin against the allow-list.
+// Entries are either exact origins, "*" or contain a single wildcard like https://*.example.com.
+// Origins are compared case-insensitively.
+func originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, pattern := range allowedOrigins {
+		pattern = strings.ToLower(pattern)
+
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if !ok || len(origin) < len(prefix)+len(suffix) {
+			continue
+		}
+
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			// The wildcard must only match a part of the host name
+			wild := origin[len(prefix) : len(origin)-len(suffix)]
+			if wild != "" && !strings.ContainsAny(wild, "/:@") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkOrigin is used by the WebSocket upgrader.
+// Requests without an Origin header stem from non-browser clients like VILLASnode and are always accepted.
+// Without a configured allow-list, only same-origin requests are accepted.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(allowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	return originAllowed(origin)
+}
+
+// corsMiddleware implements CORS for the REST API including preflight requests.
+// It wraps the whole router as preflight requests do not match any API route.
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && originAllowed(origin)
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+
+			if corsCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		// Preflight request
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
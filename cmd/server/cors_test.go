@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import "testing"
+
+func TestOriginAllowed(t *testing.T) {
+	allowedOrigins = origins{"https://App.example.com", "https://*.Example.org"}
+	t.Cleanup(func() {
+		allowedOrigins = nil
+	})
+
+	for origin, want := range map[string]bool{
+		"https://app.example.com":       true,
+		"HTTPS://APP.EXAMPLE.COM":       true,
+		"https://foo.example.org":       true,
+		"https://FOO.EXAMPLE.ORG":       true,
+		"https://example.org":           false,
+		"https://evil.com/.example.org": false,
+		"https://evil.com:.example.org": false,
+		"http://foo.example.org":        false,
+		"https://other.example.com":     false,
+	} {
+		if got := originAllowed(origin); got != want {
+			t.Errorf("originAllowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORSCredentialsWithWildcard(t *testing.T) {
+	allowedOrigins = origins{"*"}
+	corsCredentials = true
+	t.Cleanup(func() {
+		allowedOrigins = nil
+		corsCredentials = false
+	})
+
+	if err := checkCORSConfig(); err == nil {
+		t.Fatal("Credentials have been allowed for any origin")
+	}
+
+	corsCredentials = false
+
+	if err := checkCORSConfig(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
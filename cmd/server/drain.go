@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+var (
+	// Flags
+	drainTimeout time.Duration
+	drainURL     string
+
+	errDraining = errors.New("server is draining")
+
+	draining = atomic.Bool{}
+	drain    = drainState{}
+
+	// drained is called once all peers have disconnected or the drain timed out
+	drained = shutdown
+)
+
+type apiDrainResponse struct {
+	Drain pkg.DrainStatus `json:"drain"`
+}
+
+// drainState describes an ongoing drain which ends with the shutdown of the server.
+type drainState struct {
+	started  time.Time
+	deadline time.Time
+	url      string
+	mutex    sync.Mutex
+}
+
+// startDrain stops accepting new sessions and asks all connected peers to reconnect elsewhere.
+// The server shuts down once all peers have disconnected or the timeout expired.
+// It returns false if the server is already draining.
+func startDrain(u string, timeout time.Duration) bool {
+	if draining.Swap(true) {
+		return false
+	}
+
+	drain.mutex.Lock()
+	drain.started = time.Now()
+	drain.deadline = drain.started.Add(timeout)
+	drain.url = u
+	drain.mutex.Unlock()
+
+	slog.Info("Draining server",
+		slog.Duration("timeout", timeout),
+		slog.String("url", u))
+
+	for _, s := range GetSessions() {
+		if err := s.GoingAway(); err != nil {
+			s.logger.Error("Failed to notify peers", slog.Any("error", err))
+		}
+	}
+
+	go waitForDrain()
+
+	return true
+}
+
+func waitForDrain() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		status := drainStatus()
+
+		if status.Peers == 0 {
+			slog.Info("All peers disconnected")
+			break
+		}
+
+		if time.Now().After(*status.Deadline) {
+			slog.Warn("Timed-out waiting for peers to disconnect", slog.Int("peers", status.Peers))
+			break
+		}
+	}
+
+	drained()
+}
+
+func drainStatus() pkg.DrainStatus {
+	status := pkg.DrainStatus{
+		Draining: draining.Load(),
+	}
+
+	drain.mutex.Lock()
+	if !drain.started.IsZero() {
+		started, deadline := drain.started, drain.deadline
+		status.Started = &started
+		status.Deadline = &deadline
+	}
+	status.URL = drain.url
+	drain.mutex.Unlock()
+
+	for _, s := range GetSessions() {
+		_, connected := s.PeerCount()
+
+		status.Sessions++
+		status.Peers += connected
+	}
+
+	return status
+}
+
+// goingAwayMessage returns the message which asks a peer to reconnect to another instance.
+// The suggested URL includes the session and peer name if a base URL has been provided.
+func goingAwayMessage(sess, peer string) *pkg.SignalingMessage {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+
+	msg := &pkg.GoingAwayMessage{
+		Reason:   "Server is shutting down, please reconnect elsewhere",
+		Deadline: drain.deadline,
+	}
+
+	if drain.url != "" {
+		if u, err := url.Parse(drain.url); err == nil {
+			msg.URL = u.JoinPath(url.PathEscape(sess), url.PathEscape(peer)).String()
+		}
+	}
+
+	return &pkg.SignalingMessage{
+		GoingAway: msg,
+	}
+}
+
+// GoingAway sends a going away message to all connected peers of the session.
+func (s *Session) GoingAway() error {
+	return s.do(func() {
+		for _, p := range s.peers {
+			if p.conn != nil {
+				s.sendGoingAway(p)
+			}
+		}
+	})
+}
+
+func (s *Session) sendGoingAway(p *Peer) {
+	msg := SignalingMessage{
+		SignalingMessage: *goingAwayMessage(s.Name, p.Name),
+	}
+
+	if p.conn.Send(msg) {
+		s.record(&msg, []string{p.Name})
+	}
+}
+
+// checkDrainURL verifies that peers can reconnect to the URL suggested during a drain.
+func checkDrainURL(u string) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+
+	if pu.Scheme != "ws" && pu.Scheme != "wss" {
+		return fmt.Errorf("unsupported scheme: %s", pu.Scheme)
+	} else if pu.Host == "" {
+		return errors.New("missing host")
+	} else if pu.User != nil || pu.RawQuery != "" || pu.Fragment != "" {
+		return errors.New("must not contain credentials, a query or a fragment")
+	}
+
+	return nil
+}
+
+// handleAdminDrain returns the drain status or starts a drain.
+func handleAdminDrain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "POST" {
+		u := r.URL.Query().Get("url")
+		if u == "" {
+			u = drainURL
+		} else if err := checkDrainURL(u); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid URL: %w", err))
+			return
+		}
+
+		timeout := drainTimeout
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			var err error
+			if timeout, err = time.ParseDuration(t); err != nil || timeout < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid timeout: %s", t))
+				return
+			}
+		}
+
+		if !startDrain(u, timeout) {
+			writeError(w, http.StatusConflict, errDraining)
+			return
+		}
+
+		audit(r, AuditServerDrain, "", "", nil)
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	writeJSON(w, &apiDrainResponse{
+		Drain: drainStatus(),
+	})
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/google/uuid"
+)
+
+var (
+	// Names of the subscribers by their channel
+	eventSubscribers      = map[chan pkg.Event]string{}
+	eventSubscribersMutex = sync.RWMutex{}
+)
+
+// SubscribeEvents registers a channel which receives all subsequently published events.
+// Events are dropped if the channel is not ready to receive.
+// The name identifies the subscriber in logs and metrics.
+func SubscribeEvents(name string, ch chan pkg.Event) {
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	eventSubscribers[ch] = name
+}
+
+func UnsubscribeEvents(ch chan pkg.Event) {
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	delete(eventSubscribers, ch)
+}
+
+// publishEvent distributes an event to all subscribers without blocking.
+// It is called from within session goroutines.
+func publishEvent(typ pkg.EventType, sess string, peer *pkg.Peer) {
+	ev := pkg.Event{
+		ID:      uuid.New().String(),
+		Type:    typ,
+		Time:    time.Now(),
+		Session: sess,
+		Peer:    peer,
+	}
+
+	eventSubscribersMutex.RLock()
+	defer eventSubscribersMutex.RUnlock()
+
+	for ch, name := range eventSubscribers {
+		select {
+		case ch <- ev:
+		default:
+			metricEventsDropped.WithLabelValues(name).Inc()
+
+			slog.Warn("Dropped event for slow subscriber",
+				slog.String("subscriber", name),
+				slog.String("type", string(typ)),
+				slog.String("session", sess))
+		}
+	}
+}
+
+func (p *Peer) publishEvent(typ pkg.EventType) {
+	pm := p.marshal()
+	publishEvent(typ, p.session.Name, &pm)
+}
+
+// handleAPIEvents streams events as Server-Sent Events until the client disconnects.
+// The optional "session" and "type" query parameters restrict the stream
+// to the given session and event types.
+func handleAPIEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sess := q.Get("session")
+	types := q["type"]
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		subsystemLogger("api").Error("Failed to flush event stream", slog.Any("error", err))
+		return
+	}
+
+	ch := make(chan pkg.Event, 100)
+	SubscribeEvents("api", ch)
+	defer UnsubscribeEvents(ch)
+
+	id := identity(r)
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+
+		case ev := <-ch:
+			if sess != "" && ev.Session != sess {
+				continue
+			}
+
+			if len(types) > 0 && !slices.Contains(types, string(ev.Type)) {
+				continue
+			}
+
+			if id != nil && !id.CanAccessSession(ev.Session) {
+				continue
+			}
+
+			buf, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, buf); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
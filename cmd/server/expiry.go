@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+)
+
+// Reasons for the expiry of a session.
+const (
+	expiryReasonIdle  = "idle"
+	expiryReasonLease = "lease"
+	expiryReasonAdmin = "admin"
+)
+
+var (
+	// Flags
+	sessionIdleTimeout time.Duration
+	peerConnectTimeout time.Duration
+
+	errNoLease = errors.New("session has no lease and no TTL was given")
+)
+
+// touch records activity in the session. It must be called from within Session.run().
+func (s *Session) touch() {
+	s.lastActivity = time.Now()
+}
+
+// SetTTL sets a lease after which the session expires regardless of its activity.
+// A zero TTL removes the lease and reverts to the expiry after the idle timeout.
+func (s *Session) SetTTL(ttl time.Duration) error {
+	return s.do(func() {
+		s.setTTL(ttl)
+	})
+}
+
+func (s *Session) setTTL(ttl time.Duration) {
+	s.ttl = ttl
+
+	if ttl > 0 {
+		s.expires = time.Now().Add(ttl)
+	} else {
+		s.expires = time.Time{}
+	}
+}
+
+// ExtendLease renews the lease of the session by ttl or its previous TTL if zero.
+func (s *Session) ExtendLease(ttl time.Duration) (err error) {
+	if doErr := s.do(func() {
+		if ttl <= 0 {
+			ttl = s.ttl
+		}
+
+		if ttl <= 0 {
+			err = errNoLease
+			return
+		}
+
+		s.setTTL(ttl)
+	}); doErr != nil {
+		return doErr
+	}
+
+	return err
+}
+
+// checkExpiry removes peers which never connected within the peer connect timeout
+// and returns the reason why the session expired or an empty string if it did not.
+// An expired session is marked as closing in the same step,
+// so that no peer can connect between the check and the expiry.
+func (s *Session) checkExpiry(now time.Time) (reason string) {
+	s.do(func() { //nolint:errcheck
+		if s.closing {
+			return
+		}
+
+		defer func() {
+			if reason != "" {
+				s.closing = true
+			}
+		}()
+
+		removed := false
+
+		for _, p := range s.peers {
+			if peerConnectTimeout > 0 && p.conn == nil && p.connects == 0 && now.Sub(p.created) > peerConnectTimeout {
+				p.logger.Info("Removing peer which never connected", slog.Time("created", p.created))
+
+				s.removePeer(p)
+				removed = true
+
+				metricPeersExpired.Inc()
+
+				p.publishEvent(pkg.EventPeerRemoved)
+			}
+		}
+
+		if removed {
+			s.sendControlMessageToAllConnectedPeers()
+		}
+
+		// Sessions with a lease only expire once it has run out
+		if !s.expires.IsZero() {
+			if now.After(s.expires) {
+				reason = expiryReasonLease
+			}
+
+			return
+		}
+
+		for _, p := range s.peers {
+			if p.conn != nil {
+				return
+			}
+		}
+
+		if sessionIdleTimeout > 0 && now.Sub(s.lastActivity) > sessionIdleTimeout {
+			reason = expiryReasonIdle
+		}
+	})
+
+	return reason
+}
+
+// ExpireSession closes a session before its regular expiry and disconnects all its peers.
+func ExpireSession(name string) error {
+	sessionsMutex.Lock()
+
+	s, ok := sessions[name]
+	if !ok {
+		sessionsMutex.Unlock()
+		return errSessionNotFound
+	}
+
+	delete(sessions, name)
+	sessionsMutex.Unlock()
+
+	return expireSession(s, expiryReasonAdmin)
+}
+
+// expireSession closes a session which has already been removed from the sessions.
+// It must not be called while holding sessionsMutex as closing waits for the connections.
+func expireSession(s *Session, reason string) error {
+	err := s.Close()
+
+	metricSessionsExpired.WithLabelValues(reason).Inc()
+
+	publishEvent(pkg.EventSessionExpired, s.Name, nil)
+
+	return err
+}
+
+func expireSessions() {
+	now := time.Now()
+
+	for _, s := range GetSessions() {
+		reason := s.checkExpiry(now)
+		if reason == "" {
+			continue
+		}
+
+		// The session might have been deleted in the meantime
+		sessionsMutex.Lock()
+		current := sessions[s.Name] == s
+		if current {
+			delete(sessions, s.Name)
+		}
+		sessionsMutex.Unlock()
+
+		if !current {
+			continue
+		}
+
+		s.logger.Info("Session expired", slog.String("reason", reason))
+
+		if err := expireSession(s, reason); err != nil {
+			s.logger.Error("Failed to close session", slog.Any("error", err))
+		}
+	}
+}
+
+// handleAPISessionLease extends the lease of a session by the TTL given in the query
+// or the TTL which was previously set for the session.
+func handleAPISessionLease(w http.ResponseWriter, r *http.Request) {
+	sessName := mux.Vars(r)["session"]
+
+	var ttl time.Duration
+	if t := r.URL.Query().Get("ttl"); t != "" {
+		var err error
+		if ttl, err = time.ParseDuration(t); err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", t))
+			return
+		}
+	}
+
+	sess := GetSession(sessName)
+	if sess == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("failed to find session with name '%s'", sessName))
+		return
+	}
+
+	err := sess.ExtendLease(ttl)
+	audit(r, AuditSessionUpdate, sessName, "", err)
+	if errors.Is(err, errNoLease) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to extend lease: %w", err))
+		return
+	}
+
+	writeJSON(w, &apiSessionResponse{
+		Session: sess.Marshal(),
+	})
+}
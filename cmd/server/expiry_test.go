@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestExpireSessionWithLease checks that no peer can connect to a session
+// after it has been found expired and that it is removed afterwards.
+func TestExpireSessionWithLease(t *testing.T) {
+	srv := newTestServer(t)
+
+	a, err := dialPeer(srv, "lease", "a")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer a.Close()
+
+	go func() {
+		for {
+			if _, _, err := a.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sess := GetSession("lease")
+
+	if sess.Marshal().Expires != nil {
+		t.Fatal("Session without lease has an expiry time")
+	}
+
+	if err := sess.SetTTL(time.Hour); err != nil {
+		t.Fatalf("Failed to set TTL: %s", err)
+	}
+
+	if sess.Marshal().Expires == nil {
+		t.Fatal("Session with lease has no expiry time")
+	}
+
+	if reason := sess.checkExpiry(time.Now()); reason != "" {
+		t.Fatalf("Session expired before its lease: %s", reason)
+	}
+
+	if reason := sess.checkExpiry(time.Now().Add(2 * time.Hour)); reason != expiryReasonLease {
+		t.Fatalf("Unexpected expiry reason: %q", reason)
+	}
+
+	// Peers can not connect anymore once the session has expired.
+	// The connection is closed after the handshake as the peer can not be attached.
+	if b, err := dialPeer(srv, "lease", "b"); err == nil {
+		b.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+		for err == nil {
+			_, _, err = b.ReadMessage()
+		}
+
+		b.Close() //nolint:errcheck
+	}
+
+	if p := sess.GetPeer("b"); p != nil && p.IsConnected() {
+		t.Fatal("Peer connected to an expired session")
+	}
+
+	// The session expires only once
+	if reason := sess.checkExpiry(time.Now().Add(2 * time.Hour)); reason != "" {
+		t.Fatalf("Session expired twice: %s", reason)
+	}
+
+	sessionsMutex.Lock()
+	delete(sessions, sess.Name)
+	sessionsMutex.Unlock()
+
+	if err := expireSession(sess, expiryReasonLease); err != nil {
+		t.Fatalf("Failed to expire session: %s", err)
+	}
+
+	if GetSession("lease") != nil {
+		t.Fatal("Expired session has not been removed")
+	}
+}
+
+// TestExpireSessionNotFound checks that expiring an unknown session fails.
+func TestExpireSessionNotFound(t *testing.T) {
+	if err := ExpireSession("unknown"); err != errSessionNotFound {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/VILLASframework/signaling/pkg/stun"
+	pionstun "github.com/pion/stun"
+)
+
+const (
+	// Time allowed for all checks of a probe.
+	healthCheckTimeout = 2 * time.Second
+
+	// Time allowed for a STUN binding request to a relay.
+	relayProbeTimeout = 5 * time.Second
+)
+
+var (
+	// Flags
+	relayHealthInterval time.Duration
+
+	// Results of the last relay probes by relay URL
+	relayHealth      = map[string]error{}
+	relayHealthMutex = sync.Mutex{}
+)
+
+// healthCheck returns false if the check does not apply to the current configuration.
+type healthCheck func(ctx context.Context) (pkg.HealthCheck, bool)
+
+var (
+	livenessChecks = []healthCheck{
+		checkSessions,
+	}
+
+	readinessChecks = []healthCheck{
+		checkDraining,
+		checkSessions,
+		checkStorage,
+		checkRelays,
+	}
+)
+
+// handleHealth runs the checks and responds with their results.
+// The status code is 503 if any check failed.
+func handleHealth(checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		h := pkg.Health{
+			Status: pkg.HealthStatusOK,
+			Checks: []pkg.HealthCheck{},
+		}
+
+		for _, check := range checks {
+			hc, ok := check(ctx)
+			if !ok {
+				continue
+			}
+
+			if hc.Status == pkg.HealthStatusFailed {
+				h.Status = pkg.HealthStatusFailed
+			}
+
+			h.Checks = append(h.Checks, hc)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if h.Status == pkg.HealthStatusFailed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		writeJSON(w, &h)
+	}
+}
+
+func checkDraining(_ context.Context) (pkg.HealthCheck, bool) {
+	hc := pkg.HealthCheck{
+		Name:   "draining",
+		Status: pkg.HealthStatusOK,
+	}
+
+	if draining.Load() {
+		hc.Status = pkg.HealthStatusFailed
+		hc.Message = errDraining.Error()
+	}
+
+	return hc, true
+}
+
+// checkSessions verifies that the goroutines of all sessions process commands.
+func checkSessions(ctx context.Context) (pkg.HealthCheck, bool) {
+	ss := GetSessions()
+
+	stuck := []string{}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for _, s := range ss {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Sessions which are closed concurrently are not stuck
+			if err := s.Ping(ctx); err != nil && !errors.Is(err, errSessionClosed) {
+				mutex.Lock()
+				stuck = append(stuck, s.Name)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	hc := pkg.HealthCheck{
+		Name:    "sessions",
+		Status:  pkg.HealthStatusOK,
+		Message: fmt.Sprintf("%d sessions", len(ss)),
+	}
+
+	if len(stuck) > 0 {
+		sort.Strings(stuck)
+
+		hc.Status = pkg.HealthStatusFailed
+		hc.Message = fmt.Sprintf("%d of %d sessions are unresponsive: %s", len(stuck), len(ss), strings.Join(stuck, ", "))
+	}
+
+	return hc, true
+}
+
+// checkStorage verifies that the files and directories of the configured stores are accessible.
+func checkStorage(_ context.Context) (pkg.HealthCheck, bool) {
+	errs := []error{}
+	checked := false
+
+	if relayStorePath != "" {
+		checked = true
+		errs = append(errs, checkDir("relay store", filepath.Dir(relayStorePath)))
+	}
+
+	if apiKeyStore != "" {
+		checked = true
+		errs = append(errs, checkFile("API key store", apiKeyStore))
+	}
+
+	if recordDir != "" {
+		checked = true
+		errs = append(errs, checkDir("recordings", recordDir))
+	}
+
+	if !checked {
+		return pkg.HealthCheck{}, false
+	}
+
+	hc := pkg.HealthCheck{
+		Name:   "storage",
+		Status: pkg.HealthStatusOK,
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		hc.Status = pkg.HealthStatusFailed
+		hc.Message = strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
+
+	return hc, true
+}
+
+func checkDir(name, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", name, path)
+	}
+
+	return nil
+}
+
+func checkFile(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return f.Close()
+}
+
+// checkRelays reports the results of the periodic relay probes.
+// It fails only if none of the enabled relays is reachable.
+func checkRelays(_ context.Context) (pkg.HealthCheck, bool) {
+	if relayHealthInterval <= 0 {
+		return pkg.HealthCheck{}, false
+	}
+
+	infos := relayStore.Relays()
+	if len(infos) == 0 {
+		return pkg.HealthCheck{}, false
+	}
+
+	relayHealthMutex.Lock()
+	defer relayHealthMutex.Unlock()
+
+	probed := 0
+	failures := []string{}
+
+	for _, ri := range infos {
+		err, ok := relayHealth[ri.URL]
+		if !ok {
+			continue
+		}
+
+		probed++
+
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", ri.URL, err))
+		}
+	}
+
+	hc := pkg.HealthCheck{
+		Name:    "relays",
+		Status:  pkg.HealthStatusOK,
+		Message: fmt.Sprintf("%d of %d relays healthy", probed-len(failures), len(infos)),
+	}
+
+	if len(failures) > 0 {
+		hc.Status = pkg.HealthStatusDegraded
+		if len(failures) == len(infos) {
+			hc.Status = pkg.HealthStatusFailed
+		}
+
+		hc.Message += ": " + strings.Join(failures, "; ")
+	}
+
+	return hc, true
+}
+
+// Ping waits until the session goroutine executed a command or the context is cancelled.
+func (s *Session) Ping(ctx context.Context) error {
+	finished := make(chan struct{})
+
+	select {
+	case s.commands <- func() { close(finished) }:
+	case <-s.done:
+		return errSessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-s.done:
+		return errSessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startRelayHealthChecks periodically sends STUN binding requests to all enabled relays.
+func startRelayHealthChecks() {
+	logger := subsystemLogger("relays")
+
+	go func() {
+		ticker := time.NewTicker(relayHealthInterval)
+		defer ticker.Stop()
+
+		for {
+			probeRelays(logger)
+			<-ticker.C
+		}
+	}()
+}
+
+func probeRelays(logger *slog.Logger) {
+	results := map[string]error{}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for _, ri := range relayStore.Relays() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := probeRelay(ri.URL)
+
+			mutex.Lock()
+			results[ri.URL] = err
+			mutex.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	relayHealthMutex.Lock()
+	previous := relayHealth
+	relayHealth = results
+	relayHealthMutex.Unlock()
+
+	metricRelayHealthy.Reset()
+
+	for u, err := range results {
+		prevErr, known := previous[u]
+
+		if err != nil {
+			metricRelayHealthy.WithLabelValues(u).Set(0)
+
+			if !known || prevErr == nil {
+				logger.Warn("Relay is unhealthy", slog.String("relay", u), slog.Any("error", err))
+			}
+		} else {
+			metricRelayHealthy.WithLabelValues(u).Set(1)
+
+			if known && prevErr != nil {
+				logger.Info("Relay is healthy again", slog.String("relay", u))
+			}
+		}
+	}
+}
+
+func probeRelay(u string) error {
+	uri, err := pionstun.ParseURI(u)
+	if err != nil {
+		return err
+	}
+
+	_, err = stun.Bind(uri, relayProbeTimeout)
+
+	return err
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+	"github.com/pion/turn/v2"
+)
+
+// startSTUNServer starts a STUN server on the loopback interface and returns its URI.
+func startSTUNServer(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	srv, err := turn.NewServer(turn.ServerConfig{
+		AuthHandler: func(string, string, net.Addr) ([]byte, bool) {
+			return nil, false
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: conn,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start STUN server: %s", err)
+	}
+
+	t.Cleanup(func() {
+		srv.Close() //nolint:errcheck
+	})
+
+	return fmt.Sprintf("stun:%s", conn.LocalAddr())
+}
+
+// TestHealthProbes checks the transitions of the liveness and readiness probes.
+func TestHealthProbes(t *testing.T) {
+	t.Cleanup(func() {
+		draining.Store(false)
+		recordDir = ""
+		relayHealthInterval = 0
+
+		relayHealthMutex.Lock()
+		relayHealth = map[string]error{}
+		relayHealthMutex.Unlock()
+	})
+
+	r := mux.NewRouter()
+	addHealthRoutes(r)
+
+	check := func(path string, want int, checks map[string]pkg.HealthStatus) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		if rec.Code != want {
+			t.Fatalf("%s: unexpected status: got %d, want %d: %s", path, rec.Code, want, rec.Body)
+		}
+
+		h := &pkg.Health{}
+		if err := json.NewDecoder(rec.Body).Decode(h); err != nil {
+			t.Fatalf("%s: failed to decode response: %s", path, err)
+		}
+
+		got := map[string]pkg.HealthStatus{}
+		for _, hc := range h.Checks {
+			got[hc.Name] = hc.Status
+		}
+
+		for name, status := range checks {
+			if got[name] != status {
+				t.Errorf("%s: unexpected status of check %s: got %q, want %q", path, name, got[name], status)
+			}
+		}
+	}
+
+	ok := pkg.HealthStatusOK
+	failed := pkg.HealthStatusFailed
+
+	check("/livez", http.StatusOK, map[string]pkg.HealthStatus{"sessions": ok})
+	check("/readyz", http.StatusOK, map[string]pkg.HealthStatus{"draining": ok, "sessions": ok, "storage": "", "relays": ""})
+
+	// Draining servers are alive but not ready
+	draining.Store(true)
+
+	check("/livez", http.StatusOK, nil)
+	check("/readyz", http.StatusServiceUnavailable, map[string]pkg.HealthStatus{"draining": failed})
+
+	draining.Store(false)
+
+	// Inaccessible storage
+	recordDir = filepath.Join(t.TempDir(), "missing")
+
+	check("/readyz", http.StatusServiceUnavailable, map[string]pkg.HealthStatus{"storage": failed})
+
+	recordDir = t.TempDir()
+
+	check("/readyz", http.StatusOK, map[string]pkg.HealthStatus{"storage": ok})
+
+	// Relays are checked once they have been probed
+	healthy := startSTUNServer(t)
+
+	// A relay whose connections are refused
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	l.Close() //nolint:errcheck
+
+	setupTestRelays(t, "", healthy, fmt.Sprintf("turn:%s?transport=tcp", l.Addr()))
+	relayHealthInterval = time.Minute
+
+	check("/readyz", http.StatusOK, map[string]pkg.HealthStatus{"relays": ok})
+
+	probeRelays(slog.Default())
+
+	check("/readyz", http.StatusOK, map[string]pkg.HealthStatus{"relays": pkg.HealthStatusDegraded})
+
+	relayHealthMutex.Lock()
+	relayHealth[healthy] = errors.New("unreachable")
+	relayHealthMutex.Unlock()
+
+	check("/readyz", http.StatusServiceUnavailable, map[string]pkg.HealthStatus{"relays": failed})
+
+	probeRelays(slog.Default())
+
+	relayHealthMutex.Lock()
+	err = relayHealth[healthy]
+	relayHealthMutex.Unlock()
+
+	if err != nil {
+		t.Fatalf("Relay is unhealthy: %s", err)
+	}
+
+	// A session which does not process commands anymore
+	sess, err := GetOrCreateSession("stuck")
+	if err != nil {
+		t.Fatalf("Failed to create session: %s", err)
+	}
+
+	blocked, unblock := make(chan struct{}), make(chan struct{})
+	t.Cleanup(closeSessions)
+	t.Cleanup(func() {
+		close(unblock)
+	})
+
+	go sess.do(func() { //nolint:errcheck
+		close(blocked)
+		<-unblock
+	})
+
+	<-blocked
+
+	check("/livez", http.StatusServiceUnavailable, map[string]pkg.HealthStatus{"sessions": failed})
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+)
+
+const defaultJoinTTL = 24 * time.Hour
+
+var (
+	// Flags
+	joinSecret string
+	publicURL  string
+)
+
+type apiJoinResponse struct {
+	Join pkg.JoinURL `json:"join"`
+}
+
+func handleAPIJoin(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessName := vars["session"]
+	peerName := vars["peer"]
+
+	if joinSecret == "" {
+		writeError(w, http.StatusNotImplemented, errors.New("join URLs are not enabled"))
+		return
+	}
+
+	// The URL is not derived from the request as its Host header is controlled by the client
+	if publicURL == "" {
+		writeError(w, http.StatusNotImplemented, errors.New("join URLs require a public URL"))
+		return
+	}
+
+	ttl := defaultJoinTTL
+	if t := r.URL.Query().Get("ttl"); t != "" {
+		var err error
+		if ttl, err = time.ParseDuration(t); err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid TTL: %s", t))
+			return
+		}
+	}
+
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("invalid public URL: %w", err))
+		return
+	}
+
+	exp := time.Now().Add(ttl)
+
+	u = u.JoinPath(url.PathEscape(sessName), url.PathEscape(peerName))
+	pkg.SignJoinURL(u, joinSecret, sessName, peerName, exp)
+
+	audit(r, AuditJoinURLCreate, sessName, peerName, nil)
+
+	resp := &apiJoinResponse{
+		Join: pkg.JoinURL{
+			URL:     u.String(),
+			Session: sessName,
+			Peer:    peerName,
+			Expires: exp,
+		},
+	}
+
+	writeJSON(w, resp)
+}
+
+// hasSignedJoin returns true if the WebSocket request carries a valid signed join URL.
+func hasSignedJoin(r *http.Request, sess *Session, peerName string) bool {
+	return joinSecret != "" && pkg.VerifyJoin(joinSecret, sess.Name, peerName, r.URL.Query()) == nil
+}
+
+// checkJoin verifies the signed join URL of a WebSocket request for a protected session.
+func checkJoin(r *http.Request, sess *Session, peerName string) error {
+	if !sess.IsProtected() {
+		return nil
+	}
+
+	if joinSecret == "" {
+		return errors.New("session is protected but join URLs are not enabled")
+	}
+
+	if _, ok := mux.Vars(r)["peer"]; !ok || strings.TrimSpace(peerName) == "" {
+		return errors.New("protected sessions require a peer name")
+	}
+
+	return pkg.VerifyJoin(joinSecret, sess.Name, peerName, r.URL.Query())
+}
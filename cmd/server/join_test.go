@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func TestJoinURLRequiresPublicURL(t *testing.T) {
+	joinSecret = "s3cret"
+	t.Cleanup(func() {
+		joinSecret = ""
+	})
+
+	r := mux.NewRouter()
+	r.Path("/join/{session}/{peer}").HandlerFunc(handleAPIJoin)
+
+	req := httptest.NewRequest("POST", "/join/test/peer", nil)
+	req.Host = "attacker.example.com"
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("Unexpected status: %d", rec.Code)
+	}
+
+	if strings.Contains(rec.Body.String(), "attacker.example.com") {
+		t.Fatal("Join URL has been derived from the Host header")
+	}
+}
+
+// TestProtectSession checks that protecting a session disconnects peers
+// which did not join via a signed join URL.
+func TestProtectSession(t *testing.T) {
+	joinSecret = "s3cret"
+	t.Cleanup(func() {
+		joinSecret = ""
+	})
+
+	srv := newTestServer(t)
+
+	unsigned, err := dialPeer(srv, "protect", "unsigned")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer unsigned.Close()
+
+	u, _ := url.Parse("/")
+	pkg.SignJoinURL(u, joinSecret, "protect", "signed", time.Now().Add(time.Hour))
+
+	signed, err := dialPeer(srv, "protect", "signed?"+u.RawQuery)
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer signed.Close()
+
+	for _, conn := range []*websocket.Conn{unsigned, signed} {
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	sess := GetSession("protect")
+
+	if err := sess.SetProtected(true); err != nil {
+		t.Fatalf("Failed to protect session: %s", err)
+	}
+
+	waitFor(t, 5*time.Second, func() bool {
+		p := sess.GetPeer("unsigned")
+		return p == nil || !p.IsConnected()
+	})
+
+	if !sess.GetPeer("signed").IsConnected() {
+		t.Fatal("Peer with signed join URL has been disconnected")
+	}
+}
@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiKeyPrefix = "vsk"
+
+var (
+	// Flags
+	apiKeyStore string
+
+	errKeyExpired = errors.New("API key is expired")
+)
+
+// APIKey is a stored API key.
+// Only the SHA-256 hash of the secret key is persisted.
+type APIKey struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Hash     string     `json:"hash"`
+	Scopes   []Scope    `json:"scopes"`
+	Prefixes []string   `json:"prefixes,omitempty"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// HasExpiry returns true if the key expires.
+// Keys stored by earlier versions have a zero expiry time if they never expire.
+func (k *APIKey) HasExpiry() bool {
+	return k.Expires != nil && !k.Expires.IsZero()
+}
+
+func (k *APIKey) Expired() bool {
+	return k.HasExpiry() && time.Now().After(*k.Expires)
+}
+
+// KeyStore is a file-backed collection of API keys.
+// The file is reloaded whenever it has been modified,
+// e.g. by the "keys" subcommand.
+type KeyStore struct {
+	Path string
+
+	keys    []APIKey
+	modTime time.Time
+	mutex   sync.Mutex
+}
+
+type keyStoreFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+func NewKeyStore(path string) (*KeyStore, error) {
+	ks := &KeyStore{
+		Path: path,
+	}
+
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// reload reads the key file if it has been changed since it was last read.
+// A missing file is treated as an empty store.
+func (ks *KeyStore) reload() error {
+	fi, err := os.Stat(ks.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		ks.keys = nil
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat key store: %w", err)
+	}
+
+	if fi.ModTime().Equal(ks.modTime) {
+		return nil
+	}
+
+	buf, err := os.ReadFile(ks.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	f := keyStoreFile{}
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return fmt.Errorf("failed to parse key store: %w", err)
+	}
+
+	ks.keys = f.Keys
+	ks.modTime = fi.ModTime()
+
+	return nil
+}
+
+func (ks *KeyStore) save() error {
+	buf, err := json.MarshalIndent(keyStoreFile{Keys: ks.keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := ks.Path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+
+	return os.Rename(tmp, ks.Path)
+}
+
+func (ks *KeyStore) Keys() ([]APIKey, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(ks.keys), nil
+}
+
+// Create generates a new key and returns the stored key along with its secret.
+// The secret is not retrievable afterwards.
+func (ks *KeyStore) Create(name string, scopes []Scope, prefixes []string, ttl time.Duration) (APIKey, string, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if err := ks.reload(); err != nil {
+		return APIKey{}, "", err
+	}
+
+	idRaw := make([]byte, 4)
+	secretRaw := make([]byte, 32)
+	if _, err := rand.Read(idRaw); err != nil {
+		return APIKey{}, "", err
+	}
+	if _, err := rand.Read(secretRaw); err != nil {
+		return APIKey{}, "", err
+	}
+
+	id := hex.EncodeToString(idRaw)
+	secret := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, id, base64.RawURLEncoding.EncodeToString(secretRaw))
+
+	k := APIKey{
+		ID:       id,
+		Name:     name,
+		Hash:     hashKey(secret),
+		Scopes:   scopes,
+		Prefixes: prefixes,
+		Created:  time.Now(),
+	}
+
+	if ttl > 0 {
+		exp := k.Created.Add(ttl)
+		k.Expires = &exp
+	}
+
+	ks.keys = append(ks.keys, k)
+
+	if err := ks.save(); err != nil {
+		return APIKey{}, "", err
+	}
+
+	return k, secret, nil
+}
+
+func (ks *KeyStore) Revoke(id string) error {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if err := ks.reload(); err != nil {
+		return err
+	}
+
+	n := len(ks.keys)
+	ks.keys = slices.DeleteFunc(ks.keys, func(k APIKey) bool {
+		return k.ID == id
+	})
+
+	if len(ks.keys) == n {
+		return fmt.Errorf("no key with ID '%s'", id)
+	}
+
+	return ks.save()
+}
+
+// Authenticate implements the Authenticator interface.
+func (ks *KeyStore) Authenticate(_ context.Context, token string) (*Identity, error) {
+	prefix, rest, ok := strings.Cut(token, "_")
+	if !ok || prefix != apiKeyPrefix {
+		return nil, errUnknownCredentials
+	}
+
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, errUnknownCredentials
+	}
+
+	keys, err := ks.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashKey(token)
+	for _, k := range keys {
+		if k.ID != id || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) != 1 {
+			continue
+		}
+
+		if k.Expired() {
+			return nil, errKeyExpired
+		}
+
+		return &Identity{
+			Name:     k.Name,
+			Scopes:   k.Scopes,
+			Prefixes: k.Prefixes,
+		}, nil
+	}
+
+	return nil, errUnknownCredentials
+}
+
+func hashKey(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const keysUsage = `usage: %s keys -store FILE <command> [args]
+
+commands:
+  create -name NAME -scope SCOPES [-prefix PREFIXES] [-ttl DURATION]
+  list
+  revoke ID
+`
+
+// runKeysCommand implements the "keys" subcommand to manage the API key store.
+func runKeysCommand(args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ExitOnError)
+	store := fs.String("store", "", "Path of the API key store (the file passed to the server with -api-keys)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), keysUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	// There is no default to avoid managing keys in a file which is not used by the server
+	if *store == "" {
+		fs.Usage()
+		return errors.New("missing -store")
+	}
+
+	ks, err := NewKeyStore(*store)
+	if err != nil {
+		return err
+	}
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "create":
+		return runKeysCreate(ks, args)
+
+	case "list":
+		return runKeysList(ks)
+
+	case "revoke":
+		if len(args) != 1 {
+			return errors.New("usage: keys revoke ID")
+		}
+
+		return ks.Revoke(args[0])
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+func runKeysCreate(ks *KeyStore, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "Name of the key owner which is used as principal")
+	scopes := fs.String("scope", string(ScopeSessionsRead), "Comma-separated list of scopes (sessions:read, sessions:write, peers:write, relays:read, relays:write, admin)")
+	prefixes := fs.String("prefix", "", "Comma-separated list of session name prefixes to which the key is restricted")
+	ttl := fs.Duration("ttl", 0, "Validity of the key (0 for no expiry)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return errors.New("missing key name")
+	}
+
+	ss := []Scope{}
+	for _, s := range strings.Split(*scopes, ",") {
+		switch scope := Scope(s); scope {
+		case ScopeSessionsRead, ScopeSessionsWrite, ScopePeersWrite, ScopeRelaysRead, ScopeRelaysWrite, ScopeAdmin:
+			ss = append(ss, scope)
+		default:
+			return fmt.Errorf("unknown scope: %s", s)
+		}
+	}
+
+	var ps []string
+	if *prefixes != "" {
+		ps = strings.Split(*prefixes, ",")
+	}
+
+	k, secret, err := ks.Create(*name, ss, ps, *ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:  %s\n", k.ID)
+	fmt.Printf("Key: %s\n", secret)
+
+	return nil
+}
+
+func runKeysList(ks *KeyStore) error {
+	keys, err := ks.Keys()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tPREFIXES\tCREATED\tEXPIRES")
+
+	for _, k := range keys {
+		scopes := []string{}
+		for _, s := range k.Scopes {
+			scopes = append(scopes, string(s))
+		}
+
+		expires := "never"
+		if k.HasExpiry() {
+			expires = k.Expires.Format(time.RFC3339)
+			if k.Expired() {
+				expires += " (expired)"
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name,
+			strings.Join(scopes, ","),
+			strings.Join(k.Prefixes, ","),
+			k.Created.Format(time.RFC3339),
+			expires)
+	}
+
+	return tw.Flush()
+}
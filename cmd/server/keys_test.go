@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TestAPIKeyScopes checks the scope, session prefix and expiry checks of API keys.
+func TestAPIKeyScopes(t *testing.T) {
+	ks, reader := setupTestKeyStore(t, "reader", []Scope{ScopeSessionsRead}, nil, 0)
+
+	create := func(name string, scopes []Scope, prefixes []string, ttl time.Duration) string {
+		_, secret, err := ks.Create(name, scopes, prefixes, ttl)
+		if err != nil {
+			t.Fatalf("Failed to create key: %s", err)
+		}
+
+		return secret
+	}
+
+	admin := create("admin", []Scope{ScopeAdmin}, nil, 0)
+	lab := create("lab", []Scope{ScopeSessionsRead}, []string{"lab-"}, 0)
+	expired := create("expired", []Scope{ScopeSessionsRead}, nil, time.Nanosecond)
+	revoked := create("revoked", []Scope{ScopeSessionsRead}, nil, 0)
+
+	// A key with a valid ID but another secret
+	forged := reader[:len(reader)-4] + "AAAA"
+	if forged == reader {
+		forged = reader[:len(reader)-4] + "BBBB"
+	}
+
+	keys, err := ks.Keys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %s", err)
+	}
+
+	if err := ks.Revoke(keys[len(keys)-1].ID); err != nil {
+		t.Fatalf("Failed to revoke key: %s", err)
+	}
+
+	r := mux.NewRouter()
+	r.Path("/read/{session}").
+		HandlerFunc(requireScope(ScopeSessionsRead, func(http.ResponseWriter, *http.Request) {}))
+	r.Path("/write/{session}").
+		HandlerFunc(requireScope(ScopeSessionsWrite, func(http.ResponseWriter, *http.Request) {}))
+
+	for _, tc := range []struct {
+		name string
+		path string
+		auth string
+		want int
+	}{
+		{"scope", "/read/test", "Bearer " + reader, http.StatusOK},
+		{"missing scope", "/write/test", "Bearer " + reader, http.StatusForbidden},
+		{"admin scope", "/write/test", "Bearer " + admin, http.StatusOK},
+		{"matching prefix", "/read/lab-1", "Bearer " + lab, http.StatusOK},
+		{"other prefix", "/read/test", "Bearer " + lab, http.StatusForbidden},
+		{"expired", "/read/test", "Bearer " + expired, http.StatusUnauthorized},
+		{"revoked", "/read/test", "Bearer " + revoked, http.StatusUnauthorized},
+		{"wrong secret", "/read/test", "Bearer " + forged, http.StatusUnauthorized},
+		{"unknown", "/read/test", "Bearer vsk_00000000_unknown", http.StatusUnauthorized},
+		{"other scheme", "/read/test", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"missing", "/read/test", "", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.want {
+			t.Errorf("%s: unexpected status: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestAPIKeyExpires(t *testing.T) {
+	k := APIKey{
+		ID:      "01234567",
+		Created: time.Now(),
+	}
+
+	buf, err := json.Marshal(&k)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+
+	if strings.Contains(string(buf), "expires") {
+		t.Errorf("Key without expiry has an expiry time: %s", buf)
+	}
+
+	// Keys stored by earlier versions have a zero expiry time
+	legacy := APIKey{}
+	if err := json.Unmarshal([]byte(`{"id":"01234567","expires":"0001-01-01T00:00:00Z"}`), &legacy); err != nil {
+		t.Fatalf("Failed to decode key: %s", err)
+	}
+
+	if legacy.HasExpiry() || legacy.Expired() {
+		t.Error("Key with zero expiry time expires")
+	}
+
+	past := time.Now().Add(-time.Minute)
+	k.Expires = &past
+
+	if !k.Expired() {
+		t.Error("Key with past expiry time is not expired")
+	}
+}
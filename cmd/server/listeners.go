@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// First file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const systemdListenFDsStart = 3
+
+var (
+	// Flags
+	addresses       listenAddresses
+	adminAddresses  listenAddresses
+	unixSocketMode  string
+	unixSocketGroup string
+
+	systemdSockets     []systemdSocket
+	systemdSocketsErr  error
+	systemdSocketsOnce sync.Once
+)
+
+// listenAddresses is a repeatable flag of addresses in one of the forms:
+//   - HOST:PORT for a TCP listener
+//   - unix:PATH for a Unix domain socket
+//   - systemd or systemd:NAME for sockets passed via systemd socket activation
+type listenAddresses []string
+
+func (a *listenAddresses) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *listenAddresses) Set(value string) error {
+	if path, ok := strings.CutPrefix(value, "unix:"); ok && path == "" {
+		return errors.New("missing socket path")
+	}
+
+	*a = append(*a, value)
+
+	return nil
+}
+
+type systemdSocket struct {
+	name     string
+	listener net.Listener
+}
+
+// listen opens listeners for all addresses.
+func listen(addrs []string) ([]net.Listener, error) {
+	ls := []net.Listener{}
+
+	for _, addr := range addrs {
+		l, err := listenAddress(addr)
+		if err != nil {
+			for _, l := range ls {
+				l.Close() //nolint:errcheck
+			}
+
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+
+		ls = append(ls, l...)
+	}
+
+	return ls, nil
+}
+
+func listenAddress(addr string) ([]net.Listener, error) {
+	if addr == "systemd" {
+		return systemdListeners("")
+	} else if name, ok := strings.CutPrefix(addr, "systemd:"); ok {
+		return systemdListeners(name)
+	} else if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		l, err := listenUnix(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.Listener{l}, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return []net.Listener{l}, nil
+}
+
+// listenUnix creates a Unix domain socket with the configured permissions.
+// A stale socket left behind by a previous instance is removed.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := strconv.ParseUint(unixSocketMode, 8, 32)
+	if err != nil {
+		l.Close() //nolint:errcheck
+		return nil, fmt.Errorf("invalid socket mode: %s", unixSocketMode)
+	}
+
+	if err := os.Chmod(path, fs.FileMode(mode)); err != nil {
+		l.Close() //nolint:errcheck
+		return nil, fmt.Errorf("failed to change socket mode: %w", err)
+	}
+
+	if unixSocketGroup != "" {
+		gid, err := lookupGroup(unixSocketGroup)
+		if err != nil {
+			l.Close() //nolint:errcheck
+			return nil, err
+		}
+
+		if err := os.Chown(path, -1, gid); err != nil {
+			l.Close() //nolint:errcheck
+			return nil, fmt.Errorf("failed to change socket group: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(g.Gid)
+}
+
+// systemdListeners returns the sockets passed via systemd socket activation
+// whose FileDescriptorName matches name or all sockets not referenced by name if name is empty.
+func systemdListeners(name string) ([]net.Listener, error) {
+	systemdSocketsOnce.Do(func() {
+		systemdSockets, systemdSocketsErr = loadSystemdSockets()
+	})
+
+	if systemdSocketsErr != nil {
+		return nil, systemdSocketsErr
+	}
+
+	// Sockets which are referenced by name are excluded from the unnamed form
+	claimed := map[string]bool{}
+	for _, addr := range slices.Concat(addresses, adminAddresses) {
+		if n, ok := strings.CutPrefix(addr, "systemd:"); ok {
+			claimed[n] = true
+		}
+	}
+
+	ls := []net.Listener{}
+	for _, s := range systemdSockets {
+		if (name == "" && !claimed[s.name]) || s.name == name {
+			ls = append(ls, s.listener)
+		}
+	}
+
+	if len(ls) == 0 {
+		if name == "" {
+			return nil, errors.New("no sockets passed by systemd")
+		}
+
+		return nil, fmt.Errorf("no socket named %s passed by systemd", name)
+	}
+
+	return ls, nil
+}
+
+// loadSystemdSockets implements the protocol of sd_listen_fds(3).
+func loadSystemdSockets() ([]systemdSocket, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")     //nolint:errcheck
+		os.Unsetenv("LISTEN_FDS")     //nolint:errcheck
+		os.Unsetenv("LISTEN_FDNAMES") //nolint:errcheck
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("not started via systemd socket activation")
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, errors.New("invalid LISTEN_FDS")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	sockets := []systemdSocket{}
+	for i := 0; i < n; i++ {
+		fd := systemdListenFDsStart + i
+
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+
+		l, err := net.FileListener(f)
+		f.Close() //nolint:errcheck
+		if err != nil {
+			return nil, fmt.Errorf("file descriptor %d is not a listening socket: %w", fd, err)
+		}
+
+		sockets = append(sockets, systemdSocket{
+			name:     name,
+			listener: l,
+		})
+	}
+
+	return sockets, nil
+}
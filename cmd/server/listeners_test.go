@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	mode, group := unixSocketMode, unixSocketGroup
+	t.Cleanup(func() {
+		unixSocketMode, unixSocketGroup = mode, group
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signaling.sock")
+
+	unixSocketMode = "0640"
+	unixSocketGroup = strconv.Itoa(os.Getgid())
+
+	ls, err := listen([]string{"unix:" + path})
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %s", err)
+	}
+
+	if fi.Mode()&fs.ModeSocket == 0 || fi.Mode().Perm() != 0o640 {
+		t.Errorf("Unexpected mode of socket: %s", fi.Mode())
+	}
+
+	if gid := fi.Sys().(*syscall.Stat_t).Gid; int(gid) != os.Getgid() {
+		t.Errorf("Unexpected group of socket: %d", gid)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect to socket: %s", err)
+	}
+	conn.Close() //nolint:errcheck
+
+	// A socket left behind by a previous instance is replaced
+	ls[0].(*net.UnixListener).SetUnlinkOnClose(false)
+	ls[0].Close() //nolint:errcheck
+
+	l, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("Failed to replace stale socket: %s", err)
+	}
+	l.Close() //nolint:errcheck
+
+	// Other files are never removed
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create file: %s", err)
+	}
+
+	if l, err := listenUnix(file); err == nil {
+		l.Close() //nolint:errcheck
+		t.Error("Regular file has been replaced by a socket")
+	}
+
+	for _, tc := range []struct {
+		mode  string
+		group string
+	}{
+		{"rw", ""},
+		{"0660", "nonexistent-group-of-villas-signaling"},
+	} {
+		unixSocketMode = tc.mode
+		unixSocketGroup = tc.group
+
+		if l, err := listenUnix(filepath.Join(dir, "invalid.sock")); err == nil {
+			l.Close() //nolint:errcheck
+			t.Errorf("Socket with mode %q and group %q has been created", tc.mode, tc.group)
+		}
+	}
+
+	if err := (&listenAddresses{}).Set("unix:"); err == nil {
+		t.Error("Unix socket without path has been accepted")
+	}
+}
+
+func TestSystemdSocketsInvalid(t *testing.T) {
+	for _, env := range []map[string]string{
+		{},
+		{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+		{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "0"},
+		{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "many"},
+	} {
+		for _, k := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+			t.Setenv(k, env[k])
+		}
+
+		if _, err := loadSystemdSockets(); err == nil {
+			t.Errorf("Sockets have been loaded from environment %v", env)
+		}
+
+		if v := os.Getenv("LISTEN_FDS"); v != "" {
+			t.Errorf("Environment has not been cleared: LISTEN_FDS=%s", v)
+		}
+	}
+}
+
+// TestSystemdSockets passes listening sockets to a child process like systemd socket activation does.
+// The child runs TestSystemdSocketsChild which checks the listeners.
+func TestSystemdSockets(t *testing.T) {
+	files := []*os.File{}
+	addrs := []string{}
+
+	for range 2 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %s", err)
+		}
+		defer l.Close()
+
+		f, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("Failed to get file of listener: %s", err)
+		}
+		defer f.Close()
+
+		files = append(files, f)
+		addrs = append(addrs, l.Addr().String())
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemdSocketsChild$", "-test.v")
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"VILLAS_TEST_SYSTEMD_CHILD="+strings.Join(addrs, ","),
+		"LISTEN_FDS=2",
+		"LISTEN_FDNAMES=public:admin")
+
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "--- PASS: TestSystemdSocketsChild") {
+		t.Fatalf("Child failed: %v\n%s", err, out)
+	}
+}
+
+func TestSystemdSocketsChild(t *testing.T) {
+	want, ok := os.LookupEnv("VILLAS_TEST_SYSTEMD_CHILD")
+	if !ok {
+		t.Skip("Only run by TestSystemdSockets")
+	}
+
+	// systemd sets the PID after forking
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid())) //nolint:errcheck
+
+	addresses = listenAddresses{"systemd"}
+	adminAddresses = listenAddresses{"systemd:admin"}
+
+	for _, tc := range []struct {
+		addrs []string
+		want  []string
+	}{
+		{addresses, strings.Split(want, ",")[:1]},
+		{adminAddresses, strings.Split(want, ",")[1:]},
+	} {
+		ls, err := listen(tc.addrs)
+		if err != nil {
+			t.Fatalf("Failed to listen on %v: %s", tc.addrs, err)
+		}
+
+		got := []string{}
+		for _, l := range ls {
+			got = append(got, l.Addr().String())
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("Unexpected listeners for %v: got %v, want %v", tc.addrs, got, tc.want)
+		}
+	}
+
+	if _, err := listen([]string{"systemd:unknown"}); err == nil {
+		t.Error("Unknown socket name has been accepted")
+	}
+
+	for _, k := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if v := os.Getenv(k); v != "" {
+			t.Errorf("Environment has not been cleared: %s=%s", k, v)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+const subsystemKey = "subsystem"
+
+var (
+	// Flags
+	level       string
+	logFormat   string
+	logLevels   string
+	logRedact   bool
+	logSettings = &logConfig{
+		subsystems: map[string]*slog.LevelVar{},
+	}
+)
+
+// logConfig holds the global and per-subsystem log levels.
+// Levels are stored in slog.LevelVars so that they can be changed at runtime.
+type logConfig struct {
+	level      slog.LevelVar
+	subsystems map[string]*slog.LevelVar
+	mutex      sync.RWMutex
+}
+
+func (c *logConfig) Level(subsystem string) slog.Level {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if lv, ok := c.subsystems[subsystem]; ok {
+		return lv.Level()
+	}
+
+	return c.level.Level()
+}
+
+func (c *logConfig) SetLevel(subsystem string, lvl slog.Level) {
+	if subsystem == "" {
+		c.level.Set(lvl)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	lv, ok := c.subsystems[subsystem]
+	if !ok {
+		lv = &slog.LevelVar{}
+		c.subsystems[subsystem] = lv
+	}
+
+	lv.Set(lvl)
+}
+
+// Levels returns the global level and the levels of all subsystems for which one has been set.
+func (c *logConfig) Levels() (slog.Level, map[string]slog.Level) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	subsystems := map[string]slog.Level{}
+	for name, lv := range c.subsystems {
+		subsystems[name] = lv.Level()
+	}
+
+	return c.level.Level(), subsystems
+}
+
+// parseLogLevels parses a comma-separated list of subsystem=level pairs.
+func (c *logConfig) parseLogLevels(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		subsystem, lvlStr, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid subsystem log level: %s", pair)
+		}
+
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(lvlStr)); err != nil {
+			return fmt.Errorf("invalid log level for subsystem %s: %w", subsystem, err)
+		}
+
+		c.SetLevel(subsystem, lvl)
+	}
+
+	return nil
+}
+
+// setupLogging installs the default logger according to the logging flags.
+func setupLogging(w io.Writer) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	logSettings.SetLevel("", lvl)
+
+	if err := logSettings.parseLogLevels(logLevels); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{
+		// Filtering is done by the levelHandler
+		Level: slog.LevelDebug - 4,
+	}
+
+	var h slog.Handler
+	switch logFormat {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "logfmt":
+		h = slog.NewTextHandler(w, opts)
+	case "text":
+		h = newTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unsupported log format: %s", logFormat)
+	}
+
+	slog.SetDefault(slog.New(&levelHandler{
+		Handler: h,
+		config:  logSettings,
+	}))
+
+	return nil
+}
+
+// subsystemLogger returns a logger whose level can be configured via -log-levels.
+func subsystemLogger(subsystem string) *slog.Logger {
+	return slog.With(slog.String(subsystemKey, subsystem))
+}
+
+// levelHandler filters records based on the level of their subsystem.
+type levelHandler struct {
+	slog.Handler
+
+	config    *logConfig
+	subsystem string
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= h.config.Level(h.subsystem) && h.Handler.Enabled(ctx, lvl)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := &levelHandler{
+		Handler:   h.Handler.WithAttrs(attrs),
+		config:    h.config,
+		subsystem: h.subsystem,
+	}
+
+	for _, attr := range attrs {
+		if attr.Key == subsystemKey {
+			nh.subsystem = attr.Value.String()
+		}
+	}
+
+	return nh
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{
+		Handler:   h.Handler.WithGroup(name),
+		config:    h.config,
+		subsystem: h.subsystem,
+	}
+}
+
+// textHandler produces human-readable lines with a leading timestamp, level and message
+// followed by the attributes in logfmt.
+type textHandler struct {
+	attrs slog.Handler
+	buf   *bytes.Buffer
+	mutex *sync.Mutex
+	w     io.Writer
+}
+
+func newTextHandler(w io.Writer, opts *slog.HandlerOptions) *textHandler {
+	buf := &bytes.Buffer{}
+
+	return &textHandler{
+		attrs: slog.NewTextHandler(buf, &slog.HandlerOptions{
+			Level: opts.Level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return slog.Attr{}
+				}
+
+				return a
+			},
+		}),
+		buf:   buf,
+		mutex: &sync.Mutex{},
+		w:     w,
+	}
+}
+
+func (h *textHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.attrs.Enabled(ctx, lvl)
+}
+
+func (h *textHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.buf.Reset()
+	if err := h.attrs.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(h.w, "%s %-5s %s %s",
+		r.Time.Format("2006-01-02 15:04:05.000"),
+		r.Level.String(),
+		r.Message,
+		h.buf.String())
+
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &textHandler{
+		attrs: h.attrs.WithAttrs(attrs),
+		buf:   h.buf,
+		mutex: h.mutex,
+		w:     h.w,
+	}
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	return &textHandler{
+		attrs: h.attrs.WithGroup(name),
+		buf:   h.buf,
+		mutex: h.mutex,
+		w:     h.w,
+	}
+}
+
+// redactedMessage logs a signaling message without SDP bodies,
+// IP addresses of ICE candidates and relay passwords.
+type redactedMessage struct {
+	*pkg.SignalingMessage
+}
+
+func logMessage(msg *pkg.SignalingMessage) slog.Attr {
+	if !logRedact {
+		return slog.String("msg", msg.String())
+	}
+
+	return slog.Any("msg", redactedMessage{msg})
+}
+
+func (m redactedMessage) LogValue() slog.Value {
+	attrs := []slog.Attr{}
+
+	if d := m.Description; d != nil {
+		attrs = append(attrs, slog.Group("description",
+			slog.String("type", d.Type),
+			slog.String("spd", fmt.Sprintf("<redacted %d bytes>", len(d.Spd)))))
+	}
+
+	if c := m.Candidate; c != nil {
+		attrs = append(attrs, slog.Group("candidate",
+			slog.String("spd", redactCandidate(c.Spd)),
+			slog.String("mid", c.Mid)))
+	}
+
+	if c := m.Control; c != nil {
+		attrs = append(attrs, slog.Group("control",
+			slog.Any("peer_id", c.PeerID),
+			slog.Int("peers", len(c.Peers))))
+	}
+
+	if m.Signals != nil {
+		attrs = append(attrs, slog.Int("signals", len(m.Signals)))
+	}
+
+	for i, r := range m.Relays {
+		attrs = append(attrs, slog.Group(fmt.Sprintf("relay%d", i),
+			slog.String("url", r.URL),
+			slog.String("user", r.Username),
+			slog.String("expires", r.Expires)))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// redactCandidate masks all IP addresses contained in an ICE candidate.
+func redactCandidate(cand string) string {
+	fields := strings.Fields(cand)
+
+	for i, f := range fields {
+		if ip := net.ParseIP(f); ip == nil {
+			continue
+		} else if ip.To4() != nil {
+			fields[i] = "x.x.x.x"
+		} else {
+			fields[i] = "x:x:x:x:x:x:x:x"
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
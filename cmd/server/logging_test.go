@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func TestRedactCandidate(t *testing.T) {
+	for cand, want := range map[string]string{
+		"candidate:1 1 udp 2122260223 192.168.1.10 54321 typ host":                                "candidate:1 1 udp 2122260223 x.x.x.x 54321 typ host",
+		"candidate:2 1 udp 1686052607 203.0.113.7 40000 typ srflx raddr 192.168.1.10 rport 54321": "candidate:2 1 udp 1686052607 x.x.x.x 40000 typ srflx raddr x.x.x.x rport 54321",
+		"candidate:3 1 tcp 1518280447 2001:db8::1 9 typ host tcptype active":                      "candidate:3 1 tcp 1518280447 x:x:x:x:x:x:x:x 9 typ host tcptype active",
+		"candidate:4 1 udp 2122260223 4f6a8c2e-1b2d-4c3e-9f8a-0123456789ab.local 54321 typ host":  "candidate:4 1 udp 2122260223 4f6a8c2e-1b2d-4c3e-9f8a-0123456789ab.local 54321 typ host",
+	} {
+		if got := redactCandidate(cand); got != want {
+			t.Errorf("redactCandidate(%q) = %q, want %q", cand, got, want)
+		}
+	}
+}
+
+// TestLogMessageRedaction checks that SDP bodies, candidate addresses
+// and TURN credentials do not appear in logs unless redaction is disabled.
+func TestLogMessageRedaction(t *testing.T) {
+	t.Cleanup(func() {
+		logRedact = true
+	})
+
+	msg := &pkg.SignalingMessage{
+		Description: &pkg.DescriptionMessage{
+			Type: "offer",
+			Spd:  "v=0\r\no=- 4611731400430051336 2 IN IP4 198.51.100.1\r\n",
+		},
+		Candidate: &pkg.CandidateMessage{
+			Spd: "candidate:1 1 udp 2122260223 198.51.100.1 54321 typ host",
+			Mid: "0",
+		},
+		Relays: []pkg.Relay{
+			{
+				URL:      "turn:turn.example.com:3478",
+				Username: "1700000000:peer",
+				Password: "c2VjcmV0LXR1cm4tcGFzc3dvcmQ=",
+				Expires:  "2023-11-14T22:13:20Z",
+			},
+		},
+	}
+
+	secrets := []string{"198.51.100.1", "4611731400430051336", "c2VjcmV0LXR1cm4tcGFzc3dvcmQ="}
+
+	log := func() string {
+		buf := &bytes.Buffer{}
+		slog.New(slog.NewJSONHandler(buf, nil)).Info("Message", logMessage(msg))
+
+		return buf.String()
+	}
+
+	logRedact = true
+
+	out := log()
+	for _, s := range secrets {
+		if strings.Contains(out, s) {
+			t.Errorf("Redacted log contains %q: %s", s, out)
+		}
+	}
+
+	for _, s := range []string{"turn:turn.example.com:3478", "1700000000:peer", "x.x.x.x", "offer"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Redacted log is missing %q: %s", s, out)
+		}
+	}
+
+	logRedact = false
+
+	if out := log(); !strings.Contains(out, "198.51.100.1") {
+		t.Errorf("Unredacted log is missing the candidate address: %s", out)
+	}
+}
+
+func TestSubsystemLogLevels(t *testing.T) {
+	cfg := &logConfig{
+		subsystems: map[string]*slog.LevelVar{},
+	}
+
+	if err := cfg.parseLogLevels("webhook=debug,api=error"); err != nil {
+		t.Fatalf("Failed to parse log levels: %s", err)
+	}
+
+	for _, s := range []string{"webhook", "api=verbose", "api=debug,session"} {
+		invalid := &logConfig{
+			subsystems: map[string]*slog.LevelVar{},
+		}
+
+		if err := invalid.parseLogLevels(s); err == nil {
+			t.Errorf("Invalid log levels %q have been accepted", s)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(&levelHandler{
+		Handler: slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4}),
+		config:  cfg,
+	})
+
+	for _, tc := range []struct {
+		subsystem string
+		level     slog.Level
+		want      bool
+	}{
+		{"", slog.LevelInfo, true},
+		{"", slog.LevelDebug, false},
+		{"webhook", slog.LevelDebug, true},
+		{"api", slog.LevelWarn, false},
+		{"api", slog.LevelError, true},
+		{"session", slog.LevelDebug, false},
+	} {
+		buf.Reset()
+
+		l := logger
+		if tc.subsystem != "" {
+			l = logger.With(slog.String(subsystemKey, tc.subsystem))
+		}
+
+		l.Log(context.Background(), tc.level, "Test")
+
+		if got := buf.Len() > 0; got != tc.want {
+			t.Errorf("Record of subsystem %q at level %s logged: %v, want %v", tc.subsystem, tc.level, got, tc.want)
+		}
+	}
+
+	// Levels can be changed at runtime
+	cfg.SetLevel("api", slog.LevelDebug)
+
+	buf.Reset()
+	logger.With(slog.String(subsystemKey, "api")).Debug("Test")
+
+	if buf.Len() == 0 {
+		t.Error("Changed level has not been applied")
+	}
+}
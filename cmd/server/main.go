@@ -8,11 +8,14 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/VILLASframework/signaling/pkg"
@@ -21,91 +24,250 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-type relayInfos []pkg.RelayInfo
+// relayInfos holds the URIs of relays passed via flags.
+type relayInfos []string
 
 func (r *relayInfos) String() string {
 	strs := []string{}
 
-	for _, r := range *r {
-		strs = append(strs, r.URL)
+	for _, uri := range *r {
+		if ri, err := pkg.NewRelayInfo(uri); err == nil {
+			strs = append(strs, ri.String())
+		}
 	}
 
 	return strings.Join(strs, ",")
 }
 
 func (r *relayInfos) Set(value string) error {
-	ri, err := pkg.NewRelayInfo(value)
-	if err != nil {
+	if _, err := pkg.NewRelayInfo(value); err != nil {
 		return err
 	}
 
-	*r = append(*r, ri)
+	*r = append(*r, value)
 
 	return nil
 }
 
 var (
 	// Flags
-	address string
-	relays  relayInfos
-	level   string
+	relays relayInfos
 
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true },
+		CheckOrigin:     checkOrigin,
 	}
 
 	sessions      = map[string]*Session{}
 	sessionsMutex = sync.RWMutex{}
 	server        *http.Server
+	adminServer   *http.Server
+	shutdownOnce  sync.Once
 )
 
 func main() {
-	flag.StringVar(&address, "address", ":8080", "http service address")
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := runKeysCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	flag.Var(&addresses, "address", "Address to listen on like :8080, unix:/run/signaling.sock or systemd[:NAME] for socket activation (can be specified multiple times, default :8080)")
+	flag.StringVar(&adminTokenFile, "admin-token-file", "", "Path of a file with bearer tokens (one per line) for the admin API and metrics. Without tokens, they are only served on admin listeners")
+	flag.Var(&adminAddresses, "admin-address", "Address of a separate listener for the metrics and admin endpoints which are then no longer served on the public addresses (can be specified multiple times)")
+	flag.BoolVar(&metricsPublic, "metrics-public", false, "Serve /metrics on the public addresses without authentication if there is no admin listener")
+	flag.StringVar(&unixSocketMode, "unix-socket-mode", "0660", "File mode of Unix domain sockets")
+	flag.StringVar(&unixSocketGroup, "unix-socket-group", "", "Group owning Unix domain sockets")
 	flag.Var(&relays, "relay", "A TURN/STUN relay which is signalled to each connection (can be specified multiple times)")
-	flag.StringVar(&level, "level", "debug", "The log level")
+	flag.StringVar(&relayStorePath, "relay-store", "", "Path of a file in which relays managed via the API are persisted. If the file exists, it takes precedence over -relay flags")
+	flag.Var(&relayGroupRules, "relay-rule", "A rule assigning peers to a relay group like cidr:10.0.0.0/8=site-a, hint:aachen=site-a or label:site:aachen=site-a (can be specified multiple times)")
+	flag.StringVar(&relayPolicy, "relay-policy", RelayPolicyAll, "Policy for selecting TURN relays within a group (all, round-robin, least-loaded)")
+	flag.DurationVar(&relayHealthInterval, "relay-health-interval", 30*time.Second, "Interval of STUN binding requests for checking the health of relays (0 to disable)")
+	flag.StringVar(&level, "level", "info", "The log level (debug, info, warn, error)")
+	flag.StringVar(&logFormat, "log-format", "text", "The log format (text, logfmt, json)")
+	flag.StringVar(&logLevels, "log-levels", "", "Comma-separated list of per-subsystem log levels (e.g. connection=debug,webhook=warn)")
+	flag.BoolVar(&logRedact, "log-redact", true, "Redact SDP bodies, candidate addresses and relay credentials in logs")
+	flag.BoolVar(&metricsPerSession, "metrics-per-session", false, "Label metrics with the session name (increases metric cardinality)")
+	flag.BoolVar(&metricsPerPeer, "metrics-per-peer", false, "Label metrics with the session and peer names (increases metric cardinality further)")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint URL for exporting traces (e.g. http://localhost:4318)")
+	flag.Float64Var(&otlpSampleRatio, "otlp-sample-ratio", 1, "Fraction of traces which are sampled")
+	flag.StringVar(&apiKeyStore, "api-keys", "", "Path of the API key store (managed via the 'keys' subcommand). The API is unauthenticated if not set")
+	flag.StringVar(&oidcIssuer, "oidc-issuer", "", "Issuer URL of an OpenID Connect provider whose tokens are accepted by the API")
+	flag.StringVar(&oidcAudience, "oidc-audience", "", "Expected audience of OpenID Connect tokens (required with -oidc-issuer)")
+	flag.StringVar(&oidcGroupsClaim, "oidc-groups-claim", "groups", "Claim containing the groups or roles of the user (nested claims are separated by dots)")
+	flag.Var(&oidcRoles, "oidc-role", "Mapping of an OpenID Connect group to API scopes like group=sessions:read,peers:write (can be specified multiple times)")
+	flag.StringVar(&joinSecret, "join-secret", "", "Secret for signing join URLs which are required to join protected sessions")
+	flag.StringVar(&publicURL, "public-url", "", "Public WebSocket URL of the server used in join URLs (e.g. wss://signaling.example.com). Required for creating join URLs")
+	flag.Var(&allowedOrigins, "allowed-origin", "An origin like https://app.example.com or https://*.example.com which may access the API and WebSocket endpoints from a browser (can be specified multiple times)")
+	flag.BoolVar(&corsCredentials, "cors-credentials", false, "Allow credentials in cross-origin API requests")
+	flag.StringVar(&auditLogPath, "audit-log", "", "Path of a file to which an audit log of API actions is appended")
+	flag.StringVar(&auditSyslog, "audit-syslog", "", "Send audit log to syslog (\"local\" or an address like udp://host:514)")
+	flag.StringVar(&recordDir, "record-dir", "", "Directory to which the signaling messages of recorded sessions are written as JSON Lines")
+	flag.BoolVar(&recordAll, "record-all", false, "Record all sessions (otherwise recording is enabled per session via the API)")
+	flag.Var(&hooks, "webhook", "A HTTP endpoint which receives session and peer lifecycle events (can be specified multiple times)")
+	flag.StringVar(&webhookSecret, "webhook-secret", "", "Secret used to sign webhook payloads with HMAC-SHA256")
+	flag.IntVar(&webhookRetries, "webhook-retries", 5, "Number of retries for failed webhook deliveries")
+	flag.DurationVar(&sessionIdleTimeout, "session-idle-timeout", time.Hour, "Time after which sessions without connected peers and activity expire (0 to disable)")
+	flag.DurationVar(&peerConnectTimeout, "peer-connect-timeout", time.Hour, "Time after which registered peers which never connected are removed (0 to disable)")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "Time to wait for peers to disconnect when draining the server on SIGTERM before shutting down")
+	flag.StringVar(&drainURL, "drain-url", "", "WebSocket URL of another instance which is suggested to peers when draining the server (e.g. wss://signaling-2.example.com)")
 	flag.Parse()
 
-	// h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-	// 	Level: slog.LevelDebug,
-	// })
-	// slog.SetDefault(slog.New(h))
+	if err := setupLogging(os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logging: %s\n", err)
+		os.Exit(1)
+	}
 
-	r := mux.NewRouter()
+	if len(addresses) == 0 {
+		addresses = listenAddresses{":8080"}
+	}
 
-	a := r.PathPrefix("/api/v1").Subrouter()
+	if err := loadAdminTokens(); err != nil {
+		slog.Error("Failed to setup admin API", slog.Any("error", err))
+		os.Exit(1)
+	}
 
-	a.Use(
-		func(next http.Handler) http.Handler {
-			return promhttp.InstrumentHandlerCounter(metricHttpRequestsTotal, next)
-		},
-		func(next http.Handler) http.Handler {
-			return promhttp.InstrumentHandlerDuration(metricHttpRequestDuration, next)
-		},
-		func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Add("Content-Type", "application/json")
-				next.ServeHTTP(w, r)
-			})
-		},
-	)
+	if metricsPublic && len(adminAddresses) == 0 {
+		slog.Warn("Metrics are served without authentication")
+	} else if len(adminTokens) == 0 && len(adminAddresses) == 0 {
+		slog.Warn("Metrics and the admin API are disabled. Use -admin-token-file, -admin-address or -metrics-public")
+	}
+
+	if err := checkCORSConfig(); err != nil {
+		slog.Error("Invalid CORS configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if joinSecret != "" {
+		if publicURL == "" {
+			slog.Warn("Join URLs can only be verified but not created without -public-url")
+		} else if _, err := url.Parse(publicURL); err != nil {
+			slog.Error("Invalid public URL", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if drainURL != "" {
+		if err := checkDrainURL(drainURL); err != nil {
+			slog.Error("Invalid drain URL", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	switch relayPolicy {
+	case RelayPolicyAll, RelayPolicyRoundRobin, RelayPolicyLeastLoaded:
+	default:
+		slog.Error("Invalid relay policy", slog.String("policy", relayPolicy))
+		os.Exit(1)
+	}
+
+	rs, err := NewRelayStore(relayStorePath, relays)
+	if err != nil {
+		slog.Error("Failed to load relays", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	relayStore = rs
+
+	if apiKeyStore != "" {
+		ks, err := NewKeyStore(apiKeyStore)
+		if err != nil {
+			slog.Error("Failed to load API key store", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		authenticators = append(authenticators, ks)
+	}
+
+	if oidcIssuer != "" {
+		oa, err := NewOIDCAuthenticator(context.Background(), oidcIssuer, oidcAudience, oidcGroupsClaim, oidcRoles)
+		if err != nil {
+			slog.Error("Failed to setup OpenID Connect", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		authenticators = append(authenticators, oa)
+	}
+
+	if len(authenticators) == 0 {
+		slog.Warn("No API authentication configured. The API is accessible without authentication")
+	}
+
+	r := mux.NewRouter()
+	a := newAPIRouter(r)
 
 	a.Path("/sessions").
 		Methods("GET").
-		HandlerFunc(basicAuth(handleAPISessions))
+		HandlerFunc(requireScope(ScopeSessionsRead, handleAPISessions))
 
 	a.Path("/session/{session}").
 		Methods("GET").
-		HandlerFunc(handleAPISession)
+		HandlerFunc(requireScope(ScopeSessionsRead, handleAPISession))
+
+	a.Path("/session/{session}").
+		Methods("POST", "DELETE").
+		HandlerFunc(requireScope(ScopeSessionsWrite, handleAPISession))
+
+	a.Path("/session/{session}/lease").
+		Methods("POST").
+		HandlerFunc(requireScope(ScopeSessionsWrite, handleAPISessionLease))
 
 	a.Path("/peer/{session}/{peer}").
-		Methods("GET", "POST", "DELETE").
-		HandlerFunc(handleAPIPeer)
+		Methods("GET").
+		HandlerFunc(requireScope(ScopeSessionsRead, handleAPIPeer))
 
-	r.Path("/metrics").
+	a.Path("/peer/{session}/{peer}").
+		Methods("POST", "DELETE").
+		HandlerFunc(requireScope(ScopePeersWrite, handleAPIPeer))
+
+	a.Path("/join/{session}/{peer}").
+		Methods("POST").
+		HandlerFunc(requireScope(ScopePeersWrite, handleAPIJoin))
+
+	a.Path("/turn").
+		Methods("GET").
+		HandlerFunc(requireScope(ScopeRelaysRead, handleAPITURN))
+
+	a.Path("/relays").
+		Methods("GET").
+		HandlerFunc(requireScope(ScopeRelaysRead, handleAPIRelays))
+
+	a.Path("/relays").
+		Methods("POST").
+		HandlerFunc(requireScope(ScopeRelaysWrite, handleAPIRelays))
+
+	a.Path("/relays/{relay}").
+		Methods("GET").
+		HandlerFunc(requireScope(ScopeRelaysRead, handleAPIRelay))
+
+	a.Path("/relays/{relay}").
+		Methods("POST", "DELETE").
+		HandlerFunc(requireScope(ScopeRelaysWrite, handleAPIRelay))
+
+	a.Path("/events").
 		Methods("GET").
-		Handler(promhttp.Handler())
+		HandlerFunc(requireScope(ScopeSessionsRead, handleAPIEvents))
+
+	// Admin endpoints are only served by the admin listeners if there are any.
+	ar := r
+	if len(adminAddresses) > 0 {
+		ar = mux.NewRouter()
+
+		addHealthRoutes(ar)
+
+		notFound := func(rw http.ResponseWriter, r *http.Request) {
+			http.Error(rw, "Not found", http.StatusNotFound)
+		}
+
+		r.Path("/metrics").HandlerFunc(notFound)
+		r.PathPrefix("/admin/").HandlerFunc(notFound)
+	}
+
+	addAdminRoutes(ar, len(adminAddresses) > 0)
 
 	r.Path("/favicon.ico").
 		Methods("GET").
@@ -113,11 +275,7 @@ func main() {
 			http.Error(rw, "Not found", http.StatusNotFound)
 		})
 
-	r.Path("/healthz").
-		Methods("GET", "OPTIONS").
-		HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-			rw.Write([]byte("OK")) //nolint:errcheck
-		})
+	addHealthRoutes(r)
 
 	r.Path("/{session}").
 		HandlerFunc(handleWebsocket)
@@ -130,10 +288,48 @@ func main() {
 			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request"))
 		})
 
+	if err := setupAuditLog(); err != nil {
+		slog.Error("Failed to setup audit log", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		slog.Error("Failed to setup tracing", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	startWebhooks()
+
+	if relayHealthInterval > 0 {
+		startRelayHealthChecks()
+	}
+
+	listeners, err := listen(addresses)
+	if err != nil {
+		slog.Error("Failed to listen", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	adminListeners, err := listen(adminAddresses)
+	if err != nil {
+		slog.Error("Failed to listen", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	server = &http.Server{
+		Handler: corsMiddleware(r),
+	}
+
+	adminServer = &http.Server{
+		Handler: ar,
+	}
+
+	// Signals are handled once the servers exist which are stopped by shutdown()
 	expiryTicker := time.NewTicker(10 * time.Second)
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		for {
@@ -144,23 +340,105 @@ func main() {
 			case sig := <-signals:
 				slog.Debug("Received signal", slog.Any("signal", sig))
 
-				closeSessions()
-
-				if err := server.Shutdown(context.Background()); err != nil {
-					slog.Error("Failed to shutdown HTTP server", slog.Any("error", err))
+				// A second signal during a drain shuts down immediately
+				if sig == syscall.SIGTERM && startDrain(drainURL, drainTimeout) {
+					continue
 				}
+
+				shutdown()
 			}
 		}
 	}()
 
-	slog.Info("Listening", slog.String("addr", address))
+	errs := make(chan error)
+	serve := func(srv *http.Server, l net.Listener, admin bool) {
+		slog.Info("Listening",
+			slog.String("addr", l.Addr().String()),
+			slog.String("network", l.Addr().Network()),
+			slog.Bool("admin", admin))
 
-	server = &http.Server{
-		Addr:    address,
-		Handler: r,
+		errs <- srv.Serve(l)
+	}
+
+	for _, l := range listeners {
+		go serve(server, l, false)
+	}
+
+	for _, l := range adminListeners {
+		go serve(adminServer, l, true)
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		slog.Error("Failed to listen and serve", slog.Any("error", err))
+	for i := 0; i < len(listeners)+len(adminListeners); i++ {
+		if err := <-errs; err != nil && err != http.ErrServerClosed {
+			slog.Error("Failed to serve", slog.Any("error", err))
+
+			go shutdown()
+		}
 	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("Failed to shutdown tracing", slog.Any("error", err))
+	}
+}
+
+// newAPIRouter creates the subrouter for the REST API with its middlewares.
+func newAPIRouter(r *mux.Router) *mux.Router {
+	a := r.PathPrefix("/api/v1").Subrouter()
+
+	a.Use(
+		tracingMiddleware,
+		func(next http.Handler) http.Handler {
+			return promhttp.InstrumentHandlerCounter(metricHttpRequestsTotal, next)
+		},
+		func(next http.Handler) http.Handler {
+			return promhttp.InstrumentHandlerDuration(metricHttpRequestDuration, next)
+		},
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				next.ServeHTTP(w, r)
+			})
+		},
+	)
+
+	return a
+}
+
+func addHealthRoutes(r *mux.Router) {
+	r.Path("/healthz").
+		Methods("GET", "OPTIONS").
+		HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if draining.Load() {
+				http.Error(rw, "Draining", http.StatusServiceUnavailable)
+				return
+			}
+
+			rw.Write([]byte("OK")) //nolint:errcheck
+		})
+
+	r.Path("/livez").
+		Methods("GET").
+		HandlerFunc(handleHealth(livenessChecks))
+
+	r.Path("/readyz").
+		Methods("GET").
+		HandlerFunc(handleHealth(readinessChecks))
+}
+
+// shutdown closes all sessions and stops the HTTP servers.
+// It may be called multiple times, for example by a drain and a signal.
+func shutdown() {
+	shutdownOnce.Do(func() {
+		closeSessions()
+
+		for _, srv := range []*http.Server{server, adminServer} {
+			if srv == nil {
+				continue
+			}
+
+			if err := srv.Shutdown(context.Background()); err != nil {
+				slog.Error("Failed to shutdown HTTP server", slog.Any("error", err))
+			}
+		}
+	})
 }
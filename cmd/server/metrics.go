@@ -19,20 +19,17 @@ var (
 		return float64(len(sessions))
 	})
 
-	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	// The peer gauges are maintained by the session goroutines
+	// so that scrapes do not have to wait for them.
+
+	metricRegisteredPeers = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "signaling_active_peers",
-		Help: "The total number of active connections",
-	}, func() float64 {
-		sessionsMutex.RLock()
-		defer sessionsMutex.RUnlock()
+		Help: "The total number of registered peers",
+	})
 
-		cnt := 0
-		for _, s := range sessions {
-			s.mutex.RLock()
-			cnt += len(s.peers)
-			s.mutex.RUnlock()
-		}
-		return float64(cnt)
+	metricConnectedPeers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "signaling_connected_peers",
+		Help: "The total number of connected peers",
 	})
 
 	metricSessionsCreated = promauto.NewCounter(prometheus.CounterOpts{
@@ -40,6 +37,16 @@ var (
 		Help: "The total number of created sessions",
 	})
 
+	metricSessionsExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_sessions_expired_total",
+		Help: "The total number of expired sessions by reason",
+	}, []string{"reason"})
+
+	metricPeersExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "signaling_peers_expired_total",
+		Help: "The total number of registered peers removed because they never connected",
+	})
+
 	metricConnectionsCreated = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "signaling_connections",
 		Help: "The total number of created connections",
@@ -50,6 +57,67 @@ var (
 		Help: "The total number of messages exchanged",
 	}, []string{"type"})
 
+	metricEventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_events_dropped_total",
+		Help: "The total number of events dropped because a subscriber did not keep up",
+	}, []string{"subscriber"})
+
+	metricRelayHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signaling_relay_healthy",
+		Help: "Whether the last STUN binding request to a relay succeeded",
+	}, []string{"relay"})
+
+	// The following metrics carry session and peer labels which are only populated
+	// if enabled via the -metrics-per-session and -metrics-per-peer flags to bound their cardinality.
+
+	metricSessionConnectedPeers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signaling_session_connected_peers",
+		Help: "The number of connected peers per session",
+	}, []string{"session"})
+
+	metricMessagesForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_forwarded_messages_total",
+		Help: "The total number of messages received from or sent to peers",
+	}, []string{"session", "peer", "direction", "type"})
+
+	metricBytesForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_forwarded_bytes_total",
+		Help: "The total number of bytes received from or sent to peers",
+	}, []string{"session", "peer", "direction", "type"})
+
+	metricMessageSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signaling_message_size_bytes",
+		Help:    "Size of messages received from or sent to peers",
+		Buckets: prometheus.ExponentialBuckets(64, 2, 8),
+	}, []string{"direction", "type"})
+
+	metricForwardLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signaling_forward_latency_seconds",
+		Help:    "Time between receiving a message from a peer and sending it to another peer",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"session"})
+
+	metricConnectionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signaling_connection_duration_seconds",
+		Help:    "Duration of peer connections",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"session"})
+
+	metricReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_reconnects_total",
+		Help: "The total number of connections of peers which have been connected before",
+	}, []string{"session", "peer"})
+
+	metricDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_disconnects_total",
+		Help: "The total number of disconnected peers by reason",
+	}, []string{"session", "peer", "reason"})
+
+	metricRelayCredentialsIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "signaling_relay_credentials_issued_total",
+		Help: "The total number of issued relay credentials",
+	}, []string{"relay"})
+
 	metricHttpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "http_requests_total",
 		Help: "Count of all HTTP requests",
@@ -60,3 +128,45 @@ var (
 		Help: "Duration of all HTTP requests",
 	}, []string{"code", "method"})
 )
+
+var (
+	// metricsPerSession enables the session label of session-specific metrics.
+	metricsPerSession bool
+
+	// metricsPerPeer enables the peer label of peer-specific metrics.
+	// It implies metricsPerSession as peer names are only unique within a session.
+	metricsPerPeer bool
+)
+
+func sessionLabel(name string) string {
+	if metricsPerSession || metricsPerPeer {
+		return name
+	}
+
+	return ""
+}
+
+func peerLabel(name string) string {
+	if metricsPerPeer {
+		return name
+	}
+
+	return ""
+}
+
+// deleteSessionMetrics removes all series of a closed session including those of its peers.
+func deleteSessionMetrics(name string) {
+	if !metricsPerSession && !metricsPerPeer {
+		return
+	}
+
+	labels := prometheus.Labels{"session": name}
+
+	metricSessionConnectedPeers.DeletePartialMatch(labels)
+	metricMessagesForwarded.DeletePartialMatch(labels)
+	metricBytesForwarded.DeletePartialMatch(labels)
+	metricForwardLatency.DeletePartialMatch(labels)
+	metricConnectionDuration.DeletePartialMatch(labels)
+	metricReconnects.DeletePartialMatch(labels)
+	metricDisconnects.DeletePartialMatch(labels)
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestPeerGauges checks that the peer gauges are balanced
+// when peers disconnect and sessions are closed.
+func TestPeerGauges(t *testing.T) {
+	srv := newTestServer(t)
+
+	registered := testutil.ToFloat64(metricRegisteredPeers)
+	connected := testutil.ToFloat64(metricConnectedPeers)
+
+	gauges := func() (float64, float64) {
+		return testutil.ToFloat64(metricRegisteredPeers) - registered,
+			testutil.ToFloat64(metricConnectedPeers) - connected
+	}
+
+	a, err := dialPeer(srv, "gauges", "a")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer a.Close()
+
+	b, err := dialPeer(srv, "gauges", "b")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+
+	waitFor(t, 5*time.Second, func() bool {
+		r, c := gauges()
+		return r == 2 && c == 2
+	})
+
+	// Peers without signals are removed once they disconnect
+	b.Close() //nolint:errcheck
+
+	waitFor(t, 5*time.Second, func() bool {
+		r, c := gauges()
+		return r == 1 && c == 1
+	})
+
+	go func() {
+		for {
+			if _, _, err := a.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := DeleteSession("gauges"); err != nil {
+		t.Fatalf("Failed to delete session: %s", err)
+	}
+
+	if r, c := gauges(); r != 0 || c != 0 {
+		t.Fatalf("Unbalanced gauges after closing session: registered=%v, connected=%v", r, c)
+	}
+}
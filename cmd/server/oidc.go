@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// oidcRoleMapping maps values of the groups claim to API scopes.
+type oidcRoleMapping map[string][]Scope
+
+func (m *oidcRoleMapping) String() string {
+	strs := []string{}
+
+	for group, scopes := range *m {
+		ss := []string{}
+		for _, s := range scopes {
+			ss = append(ss, string(s))
+		}
+
+		strs = append(strs, group+"="+strings.Join(ss, ","))
+	}
+
+	return strings.Join(strs, " ")
+}
+
+// Set parses a mapping in the form group=scope1,scope2.
+func (m *oidcRoleMapping) Set(value string) error {
+	group, scopes, ok := strings.Cut(value, "=")
+	if !ok || group == "" {
+		return fmt.Errorf("invalid role mapping: %s", value)
+	}
+
+	if *m == nil {
+		*m = oidcRoleMapping{}
+	}
+
+	for _, s := range strings.Split(scopes, ",") {
+		(*m)[group] = append((*m)[group], Scope(s))
+	}
+
+	return nil
+}
+
+var (
+	// Flags
+	oidcIssuer      string
+	oidcAudience    string
+	oidcGroupsClaim string
+	oidcRoles       oidcRoleMapping
+)
+
+// OIDCAuthenticator validates JWT bearer tokens issued by an OpenID Connect provider.
+// The signing keys are discovered via the issuer's discovery document and cached.
+type OIDCAuthenticator struct {
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+	roles       oidcRoleMapping
+}
+
+// NewOIDCAuthenticator discovers the provider of the issuer.
+// The audience is required as tokens issued to other clients of the provider must not be accepted.
+func NewOIDCAuthenticator(ctx context.Context, issuer, audience, groupsClaim string, roles oidcRoleMapping) (*OIDCAuthenticator, error) {
+	if audience == "" {
+		return nil, errors.New("missing audience")
+	}
+
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	return &OIDCAuthenticator{
+		verifier: provider.Verifier(&oidc.Config{
+			ClientID: audience,
+		}),
+		groupsClaim: groupsClaim,
+		roles:       roles,
+	}, nil
+}
+
+// Authenticate implements the Authenticator interface.
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	// Only handle tokens which look like a JWT
+	if strings.Count(token, ".") != 2 {
+		return nil, errUnknownCredentials
+	}
+
+	idToken, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode claims: %w", err)
+	}
+
+	id := &Identity{
+		Name: idToken.Subject,
+	}
+
+	if name, ok := claims["preferred_username"].(string); ok && name != "" {
+		id.Name = name
+	}
+
+	for _, group := range claimValues(claims, a.groupsClaim) {
+		for _, scope := range a.roles[group] {
+			if !slices.Contains(id.Scopes, scope) {
+				id.Scopes = append(id.Scopes, scope)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// claimValues returns the string values of a possibly nested claim
+// addressed by a dot-separated path like "realm_access.roles".
+func claimValues(claims map[string]any, path string) []string {
+	var v any = claims
+
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		v = m[key]
+	}
+
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+
+	case []any:
+		values := []string{}
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
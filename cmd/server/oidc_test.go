@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+const testAudience = "villas-signaling"
+
+// mockIssuer is an OpenID Connect provider which serves its discovery document and keys.
+type mockIssuer struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	m := &mockIssuer{
+		key: key,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                m.URL,
+			"jwks_uri":                              m.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"alg": "RS256",
+					"use": "sig",
+					"kid": "test",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// token issues a signed JWT with the given claims on top of valid defaults.
+func (m *mockIssuer) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	c := map[string]any{
+		"iss":                m.URL,
+		"aud":                testAudience,
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+	}
+
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	encode := func(v any) string {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to encode: %s", err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	signed := encode(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"}) + "." + encode(c)
+
+	digest := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	issuer := newMockIssuer(t)
+	other := newMockIssuer(t)
+
+	roles := oidcRoleMapping{
+		"operators": {ScopeSessionsRead, ScopePeersWrite},
+	}
+
+	a, err := NewOIDCAuthenticator(context.Background(), issuer.URL, testAudience, "realm_access.roles", roles)
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %s", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		id, err := a.Authenticate(context.Background(), issuer.token(t, map[string]any{
+			"realm_access": map[string]any{
+				"roles": []string{"operators", "unknown"},
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Failed to authenticate: %s", err)
+		}
+
+		if id.Name != "alice" {
+			t.Errorf("Unexpected name: %s", id.Name)
+		}
+
+		if !slices.Equal(id.Scopes, []Scope{ScopeSessionsRead, ScopePeersWrite}) {
+			t.Errorf("Unexpected scopes: %v", id.Scopes)
+		}
+	})
+
+	for name, token := range map[string]string{
+		"wrong audience": issuer.token(t, map[string]any{"aud": "other-client"}),
+		"expired":        issuer.token(t, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong issuer":   issuer.token(t, map[string]any{"iss": other.URL}),
+		"wrong key":      other.token(t, map[string]any{"iss": issuer.URL}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Authenticate(context.Background(), token); err == nil {
+				t.Fatal("Token has been accepted")
+			}
+		})
+	}
+}
+
+func TestOIDCAuthenticatorRequiresAudience(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	if _, err := NewOIDCAuthenticator(context.Background(), issuer.URL, "", "groups", nil); err == nil {
+		t.Fatal("Authenticator without audience has been created")
+	}
+}
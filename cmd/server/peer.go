@@ -4,8 +4,8 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
-	"sync"
 	"time"
 
 	"github.com/VILLASframework/signaling/pkg"
@@ -25,18 +25,24 @@ const (
 	maxMessageSize = 4096
 )
 
+var errPeerConnected = errors.New("peer is already connected")
+
+// Peer is owned by the goroutine of its session.
+// Apart from the immutable name, session and logger, its fields must only be
+// accessed from within Session.run().
 type Peer struct {
-	Name      string
-	created   time.Time
+	Name    string
+	created time.Time
+	session *Session
+
 	id        int32
 	signals   []pkg.Signal
 	userAgent string
+	remote    string
 	connected time.Time
+	connects  int
 
-	conn    *Connection
-	session *Session
-
-	mutex sync.RWMutex
+	conn *Connection
 
 	logger *slog.Logger
 }
@@ -57,10 +63,10 @@ func (s *Session) NewPeer(name string) (*Peer, error) {
 }
 
 func (p *Peer) String() string {
-	return p.conn.RemoteAddr().String()
+	return p.Name
 }
 
-func (p *Peer) Marshal() pkg.Peer {
+func (p *Peer) marshal() pkg.Peer {
 	pm := pkg.Peer{
 		Name:      p.Name,
 		ID:        p.id,
@@ -70,17 +76,42 @@ func (p *Peer) Marshal() pkg.Peer {
 	}
 
 	if p.conn != nil {
-		pm.Remote = p.conn.RemoteAddr().String()
+		pm.Remote = p.remote
 		pm.Connected = p.connected
+		pm.Relays = p.conn.Relays()
 	}
 
 	return pm
 }
 
-func (p *Peer) Close() error {
-	if p.conn == nil {
-		return nil
+func (p *Peer) Marshal() (pm pkg.Peer) {
+	if err := p.session.do(func() {
+		pm = p.marshal()
+	}); err != nil {
+		return pkg.Peer{
+			Name:    p.Name,
+			Created: p.created,
+		}
 	}
 
-	return p.conn.Close()
+	return pm
+}
+
+// SetSignals updates the signal metadata of the peer.
+func (p *Peer) SetSignals(sigs []pkg.Signal) error {
+	return p.session.do(func() {
+		p.signals = sigs
+		p.session.touch()
+
+		p.publishEvent(pkg.EventSignalsUpdated)
+	})
+}
+
+// IsConnected returns true if the peer currently has an active connection.
+func (p *Peer) IsConnected() (connected bool) {
+	p.session.do(func() { //nolint:errcheck
+		connected = p.conn != nil
+	})
+
+	return connected
 }
@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+var (
+	// Flags
+	recordDir string
+	recordAll bool
+
+	unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+)
+
+// recorder writes the signaling messages of a session to a JSON Lines file.
+// It is owned by the session goroutine.
+type recorder struct {
+	session string
+	file    *os.File
+	enc     *json.Encoder
+}
+
+// recordingName returns the file name of a recording of the session started at t.
+// Sessions whose names only differ in unsafe characters are distinguished by a hash of the name.
+func recordingName(session string, t time.Time) string {
+	hash := sha256.Sum256([]byte(session))
+
+	return fmt.Sprintf("%s-%s-%s.jsonl",
+		unsafeFileChars.ReplaceAllString(session, "_"),
+		hex.EncodeToString(hash[:4]),
+		t.UTC().Format("20060102T150405Z"))
+}
+
+func newRecorder(session string) (*recorder, error) {
+	if recordDir == "" {
+		return nil, fmt.Errorf("recording is not enabled")
+	}
+
+	name := recordingName(session, time.Now())
+
+	f, err := os.OpenFile(filepath.Join(recordDir, name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+
+	return &recorder{
+		session: session,
+		file:    f,
+		enc:     json.NewEncoder(f),
+	}, nil
+}
+
+// Record writes a message with the time at which it has been received from its sender.
+// Messages originating from the server have no sender and are recorded with the current time.
+func (r *recorder) Record(msg *SignalingMessage, recipients []string) error {
+	rec := &pkg.Record{
+		Time:       msg.Received,
+		Session:    r.session,
+		Recipients: recipients,
+		Message:    msg.SignalingMessage,
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	if msg.Sender != nil {
+		rec.Sender = msg.Sender.Name
+	}
+
+	if rec.Recipients == nil {
+		rec.Recipients = []string{}
+	}
+
+	return r.enc.Encode(rec)
+}
+
+func (r *recorder) Close() error {
+	return r.file.Close()
+}
+
+// record appends a message to the recording of the session if enabled.
+func (s *Session) record(msg *SignalingMessage, recipients []string) {
+	if s.recorder == nil {
+		return
+	}
+
+	if err := s.recorder.Record(msg, recipients); err != nil {
+		s.logger.Error("Failed to record message", slog.Any("error", err))
+	}
+}
+
+// SetRecording starts or stops recording the messages of the session.
+func (s *Session) SetRecording(enabled bool) error {
+	var err error
+
+	if doErr := s.do(func() {
+		if enabled && s.recorder == nil {
+			s.recorder, err = newRecorder(s.Name)
+		} else if !enabled && s.recorder != nil {
+			err = s.recorder.Close()
+			s.recorder = nil
+		}
+	}); doErr != nil {
+		return doErr
+	}
+
+	return err
+}
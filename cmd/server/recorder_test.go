@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func TestRecordingName(t *testing.T) {
+	now := time.Now()
+
+	names := map[string]string{}
+	for _, session := range []string{"lab/1", "lab?1", "lab_1", "lab 1"} {
+		name := recordingName(session, now)
+
+		if other, ok := names[name]; ok {
+			t.Errorf("Sessions %q and %q are recorded to the same file %s", session, other, name)
+		}
+
+		if filepath.Base(name) != name {
+			t.Errorf("Recording name %q of session %q is not a plain file name", name, session)
+		}
+
+		names[name] = session
+	}
+
+	if recordingName("lab/1", now) != recordingName("lab/1", now) {
+		t.Error("Recording name is not stable")
+	}
+}
+
+// TestRecorder checks that messages are recorded with the time at which they have been received.
+func TestRecorder(t *testing.T) {
+	recordDir = t.TempDir()
+	t.Cleanup(func() {
+		recordDir = ""
+	})
+
+	r, err := newRecorder("lab/1")
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %s", err)
+	}
+
+	received := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	if err := r.Record(&SignalingMessage{
+		SignalingMessage: *candidateMessage(8),
+		Sender:           &Peer{Name: "a"},
+		Received:         received,
+	}, []string{"b"}); err != nil {
+		t.Fatalf("Failed to record message: %s", err)
+	}
+
+	if err := r.Record(&SignalingMessage{
+		SignalingMessage: pkg.SignalingMessage{
+			Control: &pkg.ControlMessage{},
+		},
+	}, nil); err != nil {
+		t.Fatalf("Failed to record message: %s", err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %s", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(recordDir, "lab_1-*.jsonl"))
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("Unexpected recordings: %v %v", matches, err)
+	}
+
+	f, err := os.Open(matches[0])
+	if err != nil {
+		t.Fatalf("Failed to open recording: %s", err)
+	}
+	defer f.Close()
+
+	recs := []pkg.Record{}
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		rec := pkg.Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Failed to parse record: %s", err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("Unexpected number of records: %d", len(recs))
+	}
+
+	if rec := recs[0]; !rec.Time.Equal(received) || rec.Session != "lab/1" || rec.Sender != "a" || !slices.Equal(rec.Recipients, []string{"b"}) || rec.Message.Candidate == nil {
+		t.Errorf("Unexpected record of forwarded message: %+v", rec)
+	}
+
+	if rec := recs[1]; time.Since(rec.Time) > time.Minute || rec.Sender != "" || rec.Recipients == nil || rec.Message.Control == nil {
+		t.Errorf("Unexpected record of server message: %+v", rec)
+	}
+}
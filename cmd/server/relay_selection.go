@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+const (
+	RelayPolicyAll         = "all"
+	RelayPolicyRoundRobin  = "round-robin"
+	RelayPolicyLeastLoaded = "least-loaded"
+)
+
+// relayRule assigns peers to a relay group.
+type relayRule struct {
+	// One of cidr, hint or label
+	kind string
+
+	network *net.IPNet
+	key     string
+	value   string
+
+	group string
+}
+
+type relayRules []relayRule
+
+func (r *relayRules) String() string {
+	strs := []string{}
+
+	for _, rule := range *r {
+		switch rule.kind {
+		case "cidr":
+			strs = append(strs, fmt.Sprintf("cidr:%s=%s", rule.network, rule.group))
+		case "hint":
+			strs = append(strs, fmt.Sprintf("hint:%s=%s", rule.value, rule.group))
+		case "label":
+			strs = append(strs, fmt.Sprintf("label:%s:%s=%s", rule.key, rule.value, rule.group))
+		}
+	}
+
+	return strings.Join(strs, ",")
+}
+
+// Set parses a rule in one of the following forms:
+//   - cidr:10.0.0.0/8=group
+//   - hint:value=group
+//   - label:key:value=group
+func (r *relayRules) Set(value string) error {
+	match, group, ok := strings.Cut(value, "=")
+	if !ok || group == "" {
+		return fmt.Errorf("invalid relay rule: %s", value)
+	}
+
+	kind, arg, ok := strings.Cut(match, ":")
+	if !ok {
+		return fmt.Errorf("invalid relay rule: %s", value)
+	}
+
+	rule := relayRule{
+		kind:  kind,
+		group: group,
+	}
+
+	switch kind {
+	case "cidr":
+		_, network, err := net.ParseCIDR(arg)
+		if err != nil {
+			return fmt.Errorf("invalid relay rule: %w", err)
+		}
+
+		rule.network = network
+
+	case "hint":
+		rule.value = arg
+
+	case "label":
+		if rule.key, rule.value, ok = strings.Cut(arg, ":"); !ok {
+			return fmt.Errorf("invalid label in relay rule: %s", arg)
+		}
+
+	default:
+		return fmt.Errorf("unknown relay rule type: %s", kind)
+	}
+
+	*r = append(*r, rule)
+
+	return nil
+}
+
+// relaySelection holds the properties of a peer on which relays are selected.
+type relaySelection struct {
+	Remote net.IP
+	Hint   string
+	Labels map[string]string
+}
+
+func (r *relayRule) matches(sel relaySelection) bool {
+	switch r.kind {
+	case "cidr":
+		return sel.Remote != nil && r.network.Contains(sel.Remote)
+	case "hint":
+		return sel.Hint == r.value
+	case "label":
+		v, ok := sel.Labels[r.key]
+		return ok && v == r.value
+	}
+
+	return false
+}
+
+var (
+	// Flags
+	relayGroupRules relayRules
+	relayPolicy     string
+
+	relayCounter   atomic.Uint64
+	relayLoad      = map[string]int{}
+	relayLoadMutex sync.Mutex
+)
+
+// group returns the relay group for a peer.
+// The first matching rule wins. Otherwise a hint naming an existing group is used.
+func (sel relaySelection) group(infos []pkg.RelayInfo) string {
+	for _, rule := range relayGroupRules {
+		if rule.matches(sel) {
+			return rule.group
+		}
+	}
+
+	if sel.Hint != "" {
+		for _, ri := range infos {
+			if ri.Group == sel.Hint {
+				return sel.Hint
+			}
+		}
+	}
+
+	return ""
+}
+
+// selectRelays picks the relays for a peer based on the relay group rules and policy.
+// The returned function must be called once the relays are no longer used by the peer.
+func selectRelays(infos []pkg.RelayInfo, sel relaySelection) ([]pkg.RelayInfo, func()) {
+	group := sel.group(infos)
+
+	// Relays without a group are shared by all groups
+	candidates := []pkg.RelayInfo{}
+	for _, ri := range infos {
+		if ri.Group == group || ri.Group == "" {
+			candidates = append(candidates, ri)
+		}
+	}
+
+	// Relays of other groups are never handed out
+	if len(candidates) == 0 && len(infos) > 0 {
+		subsystemLogger("relays").Warn("No relays available for group",
+			slog.String("group", group),
+			slog.Int("relays", len(infos)))
+	}
+
+	stuns := []pkg.RelayInfo{}
+	turns := []pkg.RelayInfo{}
+	for _, ri := range candidates {
+		if strings.HasPrefix(ri.URL, "stun") {
+			stuns = append(stuns, ri)
+		} else {
+			turns = append(turns, ri)
+		}
+	}
+
+	relayLoadMutex.Lock()
+	defer relayLoadMutex.Unlock()
+
+	if len(turns) > 1 {
+		switch relayPolicy {
+		case RelayPolicyRoundRobin:
+			i := relayCounter.Add(1) % uint64(len(turns))
+			turns = turns[i : i+1]
+
+		case RelayPolicyLeastLoaded:
+			least := turns[0]
+			for _, ri := range turns[1:] {
+				if relayLoad[ri.URL] < relayLoad[least.URL] {
+					least = ri
+				}
+			}
+
+			turns = []pkg.RelayInfo{least}
+		}
+	}
+
+	for _, ri := range turns {
+		relayLoad[ri.URL]++
+	}
+
+	release := sync.OnceFunc(func() {
+		relayLoadMutex.Lock()
+		defer relayLoadMutex.Unlock()
+
+		for _, ri := range turns {
+			if relayLoad[ri.URL]--; relayLoad[ri.URL] <= 0 {
+				delete(relayLoad, ri.URL)
+			}
+		}
+	})
+
+	return append(stuns, turns...), release
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func TestSelectRelaysGroups(t *testing.T) {
+	if err := relayGroupRules.Set("hint:eu=eu"); err != nil {
+		t.Fatalf("Failed to parse rule: %s", err)
+	}
+
+	if err := relayGroupRules.Set("hint:us=us"); err != nil {
+		t.Fatalf("Failed to parse rule: %s", err)
+	}
+
+	t.Cleanup(func() {
+		relayGroupRules = nil
+	})
+
+	infos := []pkg.RelayInfo{
+		{URL: "turn:eu.example.com", Group: "eu"},
+		{URL: "turn:asia.example.com", Group: "asia"},
+	}
+
+	urls := func(hint string) []string {
+		rs, release := selectRelays(infos, relaySelection{Hint: hint})
+		defer release()
+
+		urls := []string{}
+		for _, ri := range rs {
+			urls = append(urls, ri.URL)
+		}
+
+		return urls
+	}
+
+	if got := urls("eu"); !slices.Equal(got, []string{"turn:eu.example.com"}) {
+		t.Errorf("Unexpected relays for group eu: %v", got)
+	}
+
+	// Peers must not receive relays of other groups
+	if got := urls("us"); len(got) > 0 {
+		t.Errorf("Relays of other groups have been selected: %v", got)
+	}
+
+	infos = append(infos, pkg.RelayInfo{URL: "stun:stun.example.com"})
+
+	if got := urls("us"); !slices.Equal(got, []string{"stun:stun.example.com"}) {
+		t.Errorf("Unexpected relays for group us: %v", got)
+	}
+}
@@ -0,0 +1,315 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+var (
+	// Flags
+	relayStorePath string
+
+	relayStore = &RelayStore{}
+
+	errRelayNotFound  = errors.New("relay not found")
+	errRelayDuplicate = errors.New("relay already exists")
+)
+
+// StoredRelay is a relay which can be managed at runtime.
+// The URI includes credentials and secrets and is therefore never returned by the API.
+type StoredRelay struct {
+	ID       string    `json:"id"`
+	URI      string    `json:"uri"`
+	Disabled bool      `json:"disabled,omitempty"`
+	Created  time.Time `json:"created"`
+
+	info pkg.RelayInfo
+}
+
+func (sr *StoredRelay) marshal() pkg.RelayConfig {
+	rc := pkg.RelayConfig{
+		ID:       sr.ID,
+		URL:      sr.info.URL,
+		Group:    sr.info.Group,
+		Realm:    sr.info.Realm,
+		Disabled: sr.Disabled,
+		Created:  sr.Created,
+	}
+
+	if sr.info.Username != "" && sr.info.Password != "" {
+		rc.Auth = "static"
+	} else if sr.info.HasSecrets() {
+		rc.Auth = "rest"
+		rc.Algorithm = string(sr.info.Algorithm)
+		rc.TTL = int(sr.info.TTL.Seconds())
+	}
+
+	relayLoadMutex.Lock()
+	rc.Peers = relayLoad[sr.info.URL]
+	relayLoadMutex.Unlock()
+
+	return rc
+}
+
+// RelayStore holds the relays which are signalled to peers.
+// If a path is configured, changes are persisted to it
+// and the relays are restored from it on startup.
+type RelayStore struct {
+	Path string
+
+	relays []StoredRelay
+	mutex  sync.RWMutex
+}
+
+type relayStoreFile struct {
+	Relays []StoredRelay `json:"relays"`
+}
+
+// NewRelayStore loads the relays from the file at path.
+// If no path is given or the file does not exist yet, the store is initialized with the given URIs.
+func NewRelayStore(path string, uris []string) (*RelayStore, error) {
+	rs := &RelayStore{
+		Path: path,
+	}
+
+	if path != "" {
+		buf, err := os.ReadFile(path)
+		if err == nil {
+			f := relayStoreFile{}
+			if err := json.Unmarshal(buf, &f); err != nil {
+				return nil, fmt.Errorf("failed to parse relay store: %w", err)
+			}
+
+			for _, sr := range f.Relays {
+				if sr.info, err = pkg.NewRelayInfo(sr.URI); err != nil {
+					return nil, fmt.Errorf("invalid relay %s in store: %w", sr.ID, err)
+				}
+
+				rs.relays = append(rs.relays, sr)
+			}
+
+			return rs, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read relay store: %w", err)
+		}
+	}
+
+	for _, uri := range uris {
+		if _, err := rs.add(uri, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if path != "" {
+		if err := rs.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+func (rs *RelayStore) save() error {
+	if rs.Path == "" {
+		return nil
+	}
+
+	buf, err := json.MarshalIndent(relayStoreFile{Relays: rs.relays}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := rs.Path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return fmt.Errorf("failed to write relay store: %w", err)
+	}
+
+	return os.Rename(tmp, rs.Path)
+}
+
+func (rs *RelayStore) add(uri string, disabled bool) (StoredRelay, error) {
+	info, err := pkg.NewRelayInfo(uri)
+	if err != nil {
+		return StoredRelay{}, err
+	}
+
+	for _, sr := range rs.relays {
+		if sr.info.URL == info.URL {
+			return StoredRelay{}, fmt.Errorf("%w: %s", errRelayDuplicate, info.URL)
+		}
+	}
+
+	idRaw := make([]byte, 4)
+	if _, err := rand.Read(idRaw); err != nil {
+		return StoredRelay{}, err
+	}
+
+	sr := StoredRelay{
+		ID:       hex.EncodeToString(idRaw),
+		URI:      uri,
+		Disabled: disabled,
+		Created:  time.Now(),
+		info:     info,
+	}
+
+	rs.relays = append(rs.relays, sr)
+
+	return sr, nil
+}
+
+func (rs *RelayStore) index(id string) int {
+	for i, sr := range rs.relays {
+		if sr.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Relays returns all enabled relays.
+func (rs *RelayStore) Relays() []pkg.RelayInfo {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	infos := []pkg.RelayInfo{}
+	for _, sr := range rs.relays {
+		if !sr.Disabled {
+			infos = append(infos, sr.info)
+		}
+	}
+
+	return infos
+}
+
+// List returns all relays including disabled ones.
+func (rs *RelayStore) List() []pkg.RelayConfig {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	rcs := []pkg.RelayConfig{}
+	for _, sr := range rs.relays {
+		rcs = append(rcs, sr.marshal())
+	}
+
+	return rcs
+}
+
+func (rs *RelayStore) Get(id string) (pkg.RelayConfig, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	i := rs.index(id)
+	if i < 0 {
+		return pkg.RelayConfig{}, errRelayNotFound
+	}
+
+	return rs.relays[i].marshal(), nil
+}
+
+// Add parses and stores a new relay.
+func (rs *RelayStore) Add(uri string, disabled bool) (pkg.RelayConfig, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	sr, err := rs.add(uri, disabled)
+	if err != nil {
+		return pkg.RelayConfig{}, err
+	}
+
+	if err := rs.save(); err != nil {
+		rs.relays = rs.relays[:len(rs.relays)-1]
+		return pkg.RelayConfig{}, err
+	}
+
+	return sr.marshal(), nil
+}
+
+// Update replaces the URI and/or the disabled state of a relay.
+// Nil arguments are left unchanged.
+func (rs *RelayStore) Update(id string, uri *string, disabled *bool) (pkg.RelayConfig, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	i := rs.index(id)
+	if i < 0 {
+		return pkg.RelayConfig{}, errRelayNotFound
+	}
+
+	old := rs.relays[i]
+	sr := old
+
+	if uri != nil {
+		info, err := pkg.NewRelayInfo(*uri)
+		if err != nil {
+			return pkg.RelayConfig{}, err
+		}
+
+		for j, other := range rs.relays {
+			if j != i && other.info.URL == info.URL {
+				return pkg.RelayConfig{}, fmt.Errorf("%w: %s", errRelayDuplicate, info.URL)
+			}
+		}
+
+		sr.URI = *uri
+		sr.info = info
+	}
+
+	if disabled != nil {
+		sr.Disabled = *disabled
+	}
+
+	rs.relays[i] = sr
+
+	if err := rs.save(); err != nil {
+		rs.relays[i] = old
+		return pkg.RelayConfig{}, err
+	}
+
+	return sr.marshal(), nil
+}
+
+func (rs *RelayStore) Delete(id string) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	i := rs.index(id)
+	if i < 0 {
+		return errRelayNotFound
+	}
+
+	old := rs.relays[i]
+	rs.relays = append(rs.relays[:i], rs.relays[i+1:]...)
+
+	if err := rs.save(); err != nil {
+		rs.relays = append(rs.relays[:i], append([]StoredRelay{old}, rs.relays[i:]...)...)
+		return err
+	}
+
+	return nil
+}
+
+// pushRelays re-selects the relays for all connected peers
+// and sends them an updated relays message.
+func pushRelays() {
+	for _, s := range GetSessions() {
+		s.do(func() { //nolint:errcheck
+			for _, p := range s.peers {
+				if p.conn != nil {
+					p.conn.UpdateRelays()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Minimal time before expiry at which relay credentials are refreshed.
+	relayRefreshMargin = time.Minute
+
+	// Minimal time until relay credentials are refreshed.
+	relayRefreshMinDelay = 5 * time.Second
+)
+
+// getRelays returns the relays including credentials for the given TURN username.
+// The returned time is the earliest expiry of the credentials or zero if none expire.
+func getRelays(infos []pkg.RelayInfo, username string) ([]pkg.Relay, time.Time) {
+	rs := []pkg.Relay{}
+	earliest := time.Time{}
+
+	for _, relay := range infos {
+		user, pass, exp := relay.GetCredentials(username)
+		r := pkg.Relay{
+			URL:      relay.URL,
+			Username: user,
+			Password: pass,
+			Realm:    relay.Realm,
+		}
+
+		if !exp.IsZero() {
+			r.Expires = exp.Format(time.RFC3339)
+
+			if earliest.IsZero() || exp.Before(earliest) {
+				earliest = exp
+			}
+		}
+
+		rs = append(rs, r)
+
+		metricRelayCredentialsIssued.WithLabelValues(relay.URL).Inc()
+	}
+
+	return rs, earliest
+}
+
+// relayRefreshTime returns the time at which credentials expiring at exp should be renewed.
+// This is a tenth of their lifetime but at least relayRefreshMargin before their expiry.
+// For short lifetimes, the margin is limited to half of the lifetime so that the refresh
+// is not scheduled in the past. The refresh is never scheduled earlier than relayRefreshMinDelay
+// from now to avoid refreshing continuously for credentials which are (almost) expired.
+func relayRefreshTime(exp time.Time) time.Time {
+	now := time.Now()
+	lifetime := exp.Sub(now)
+	margin := min(max(lifetime/10, relayRefreshMargin), lifetime/2)
+
+	if refresh := exp.Add(-margin); refresh.After(now.Add(relayRefreshMinDelay)) {
+		return refresh
+	}
+
+	return now.Add(relayRefreshMinDelay)
+}
+
+// relayUsername returns the TURN username for a peer to attribute relay usage in TURN server logs.
+func relayUsername(p *Peer) string {
+	return p.session.Name + "/" + p.Name
+}
+
+// handleAPITURN implements the response format of the TURN REST API
+// as described in draft-uberti-behave-turn-rest.
+//
+// The credentials of the first selected relay which requires authentication are returned
+// along with the URIs of all relays accepting the same credentials as well as all STUN relays.
+// The TURN username is taken from the authenticated principal or the "username" query parameter.
+// Relays are selected based on the remote address and the optional "hint" query parameter.
+func handleAPITURN(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if svc := q.Get("service"); svc != "" && svc != "turn" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported service: %s", svc))
+		return
+	}
+
+	username := q.Get("username")
+	if id := identity(r); id != nil {
+		username = id.Name
+	}
+
+	if username == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing username"))
+		return
+	}
+
+	resp := &pkg.TURNCredentials{
+		URIs: []string{},
+	}
+
+	selected, release := selectRelays(relayStore.Relays(), relaySelection{
+		Remote: remoteIP(r.RemoteAddr),
+		Hint:   q.Get("hint"),
+	})
+	release()
+
+	for _, relay := range selected {
+		user, pass, exp := relay.GetCredentials(username)
+
+		switch {
+		case user == "" && strings.HasPrefix(relay.URL, "stun"):
+			resp.URIs = append(resp.URIs, relay.URL)
+			continue
+
+		case user == "":
+			continue
+
+		case resp.Username == "":
+			resp.Username = user
+			resp.Password = pass
+			resp.TTL = int(relay.TTL.Seconds())
+
+			if !exp.IsZero() {
+				resp.TTL = int(time.Until(exp).Seconds())
+			}
+
+		case resp.Username != user || resp.Password != pass:
+			continue
+		}
+
+		resp.URIs = append(resp.URIs, relay.URL)
+
+		metricRelayCredentialsIssued.WithLabelValues(relay.URL).Inc()
+	}
+
+	audit(r, AuditTURNCredentials, "", "", nil)
+
+	writeJSON(w, resp)
+}
+
+// remoteIP returns the IP address of a host:port pair or nil.
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return net.ParseIP(host)
+}
+
+type apiRelayRequest struct {
+	Relay *struct {
+		URI      *string `json:"uri"`
+		Disabled *bool   `json:"disabled"`
+	} `json:"relay"`
+}
+
+type apiRelayResponse struct {
+	Relay pkg.RelayConfig `json:"relay"`
+}
+
+type apiRelaysResponse struct {
+	Relays []pkg.RelayConfig `json:"relays"`
+}
+
+func readRelayRequest(w http.ResponseWriter, r *http.Request) (*apiRelayRequest, bool) {
+	req := &apiRelayRequest{}
+	if !readJSON(w, r, req) {
+		return nil, false
+	}
+
+	if req.Relay == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing relay"))
+		return nil, false
+	}
+
+	return req, true
+}
+
+// handleAPIRelays lists all relays or adds a new one.
+// Connected peers receive an updated list of relays after a change.
+func handleAPIRelays(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		req, ok := readRelayRequest(w, r)
+		if !ok {
+			return
+		}
+
+		if req.Relay.URI == nil {
+			writeError(w, http.StatusBadRequest, errors.New("missing relay URI"))
+			return
+		}
+
+		disabled := req.Relay.Disabled != nil && *req.Relay.Disabled
+
+		rc, err := relayStore.Add(*req.Relay.URI, disabled)
+		audit(r, AuditRelayCreate, "", "", err)
+		if errors.Is(err, errRelayDuplicate) {
+			writeError(w, http.StatusConflict, err)
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to add relay: %w", err))
+			return
+		}
+
+		pushRelays()
+
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, &apiRelayResponse{
+			Relay: rc,
+		})
+
+		return
+	}
+
+	writeJSON(w, &apiRelaysResponse{
+		Relays: relayStore.List(),
+	})
+}
+
+// handleAPIRelay returns, updates or deletes a single relay.
+func handleAPIRelay(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["relay"]
+
+	var (
+		rc  pkg.RelayConfig
+		err error
+	)
+
+	switch r.Method {
+	case "POST":
+		req, ok := readRelayRequest(w, r)
+		if !ok {
+			return
+		}
+
+		rc, err = relayStore.Update(id, req.Relay.URI, req.Relay.Disabled)
+		audit(r, AuditRelayUpdate, "", "", err)
+
+	case "DELETE":
+		err = relayStore.Delete(id)
+		audit(r, AuditRelayDelete, "", "", err)
+
+	default:
+		rc, err = relayStore.Get(id)
+	}
+
+	if errors.Is(err, errRelayNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, errRelayDuplicate) {
+		writeError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to update relay: %w", err))
+		return
+	}
+
+	if r.Method != "GET" {
+		pushRelays()
+	}
+
+	if r.Method == "DELETE" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, &apiRelayResponse{
+		Relay: rc,
+	})
+}
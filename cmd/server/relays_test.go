@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+)
+
+func TestRelayRefreshTime(t *testing.T) {
+	for _, tc := range []struct {
+		lifetime time.Duration
+		margin   time.Duration
+	}{
+		{24 * time.Hour, 144 * time.Minute},
+		{5 * time.Minute, relayRefreshMargin},
+		{time.Minute, 30 * time.Second},
+		{20 * time.Second, 10 * time.Second},
+	} {
+		exp := time.Now().Add(tc.lifetime)
+		refresh := relayRefreshTime(exp)
+
+		if !refresh.After(time.Now()) {
+			t.Errorf("Refresh of credentials with a lifetime of %s is scheduled in the past", tc.lifetime)
+		}
+
+		// Allow for the time passed since exp has been calculated
+		if margin := exp.Sub(refresh); margin > tc.margin || margin < tc.margin-time.Second {
+			t.Errorf("Unexpected margin for lifetime %s: got %s, want %s", tc.lifetime, margin, tc.margin)
+		}
+	}
+}
+
+// TestRelayRefreshMinDelay checks that credentials which are about to expire
+// or have already expired are not refreshed immediately.
+func TestRelayRefreshMinDelay(t *testing.T) {
+	for _, lifetime := range []time.Duration{10 * time.Second, time.Second, 0, -time.Hour} {
+		refresh := relayRefreshTime(time.Now().Add(lifetime))
+
+		if delay := time.Until(refresh); delay < relayRefreshMinDelay-time.Second {
+			t.Errorf("Refresh of credentials with a lifetime of %s is scheduled in %s", lifetime, delay)
+		}
+	}
+}
+
+// setupTestRelays replaces the relays by the given URIs for the duration of the test.
+func setupTestRelays(t *testing.T, path string, uris ...string) *RelayStore {
+	t.Helper()
+
+	rs, err := NewRelayStore(path, uris)
+	if err != nil {
+		t.Fatalf("Failed to create relay store: %s", err)
+	}
+
+	old := relayStore
+	relayStore = rs
+	t.Cleanup(func() {
+		relayStore = old
+	})
+
+	return rs
+}
+
+// TestTURNCredentials checks the response of the TURN REST API endpoint.
+func TestTURNCredentials(t *testing.T) {
+	setupTestRelays(t, "",
+		"stun:stun.example.com",
+		"turn:turn.example.com?secret=s3cret&ttl=1h",
+		"turns:turn.example.com?secret=s3cret&ttl=1h",
+		"turn:other.example.com?secret=other")
+
+	r := mux.NewRouter()
+	r.Path("/turn").HandlerFunc(requireScope(ScopeRelaysRead, handleAPITURN))
+
+	get := func(query, token string) (*httptest.ResponseRecorder, *pkg.TURNCredentials) {
+		req := httptest.NewRequest("GET", "/turn?"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		resp := &pkg.TURNCredentials{}
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+				t.Fatalf("Failed to decode response: %s", err)
+			}
+		}
+
+		return rec, resp
+	}
+
+	rec, resp := get("service=turn&username=alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", rec.Code)
+	}
+
+	if !strings.HasSuffix(resp.Username, ":alice") {
+		t.Errorf("Unexpected username: %s", resp.Username)
+	}
+
+	if resp.TTL < 3590 || resp.TTL > 3600 {
+		t.Errorf("Unexpected TTL: %d", resp.TTL)
+	}
+
+	ri := pkg.RelayInfo{Secrets: []string{"s3cret"}}
+	if _, err := ri.VerifyCredentials(resp.Username, resp.Password); err != nil {
+		t.Errorf("Invalid credentials: %s", err)
+	}
+
+	// Relays with other secrets do not accept the credentials
+	if want := []string{"stun:stun.example.com:3478", "turn:turn.example.com:3478?transport=udp", "turns:turn.example.com:5349?transport=tcp"}; !slices.Equal(resp.URIs, want) {
+		t.Errorf("Unexpected URIs: got %v, want %v", resp.URIs, want)
+	}
+
+	for _, query := range []string{"service=stun&username=alice", ""} {
+		if rec, _ := get(query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status for query %q: %d", query, rec.Code)
+		}
+	}
+
+	// The username of authenticated clients is taken from their identity
+	_, secret := setupTestKeyStore(t, "operator", []Scope{ScopeRelaysRead}, nil, 0)
+
+	if rec, resp := get("username=mallory", secret); rec.Code != http.StatusOK || !strings.HasSuffix(resp.Username, ":operator") {
+		t.Errorf("Unexpected response for authenticated client: %d %s", rec.Code, resp.Username)
+	}
+}
+
+// TestRelaysAPI checks the management of relays at runtime
+// and that connected peers receive the changed relays.
+func TestRelaysAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relays.json")
+	setupTestRelays(t, path, "stun:stun.example.com")
+
+	srv := newTestServer(t)
+
+	conn, err := dialPeer(srv, "relays", "a")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer conn.Close()
+
+	r := mux.NewRouter()
+	r.Path("/relays").Methods("GET", "POST").HandlerFunc(handleAPIRelays)
+	r.Path("/relays/{relay}").Methods("GET", "POST", "DELETE").HandlerFunc(handleAPIRelay)
+
+	do := func(method, path, body string, want int, resp any) {
+		t.Helper()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Fatalf("%s %s: unexpected status: got %d, want %d: %s", method, path, rec.Code, want, rec.Body)
+		}
+
+		if resp != nil {
+			if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+				t.Fatalf("%s %s: failed to decode response: %s", method, path, err)
+			}
+		}
+	}
+
+	// expectRelays waits for a relays message with the given URLs
+	expectRelays := func(want ...string) {
+		t.Helper()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+		for {
+			msg := &pkg.SignalingMessage{}
+			if err := conn.ReadJSON(msg); err != nil {
+				t.Fatalf("Failed to receive relays: %s", err)
+			}
+
+			if msg.Relays == nil {
+				continue
+			}
+
+			urls := []string{}
+			for _, r := range msg.Relays {
+				urls = append(urls, r.URL)
+			}
+
+			if slices.Equal(urls, want) {
+				return
+			}
+		}
+	}
+
+	list := &apiRelaysResponse{}
+	do("GET", "/relays", "", http.StatusOK, list)
+
+	if len(list.Relays) != 1 || list.Relays[0].URL != "stun:stun.example.com:3478" {
+		t.Fatalf("Unexpected relays: %+v", list.Relays)
+	}
+
+	created := &apiRelayResponse{}
+	do("POST", "/relays", `{"relay":{"uri":"turn:turn.example.com?secret=s3cret&algorithm=sha256"}}`, http.StatusCreated, created)
+
+	if rc := created.Relay; rc.ID == "" || rc.Auth != "rest" || rc.Algorithm != "sha256" {
+		t.Errorf("Unexpected relay: %+v", rc)
+	}
+
+	expectRelays("stun:stun.example.com:3478", "turn:turn.example.com:3478?transport=udp")
+
+	id := created.Relay.ID
+
+	do("POST", "/relays", `{"relay":{"uri":"turn:turn.example.com?secret=other"}}`, http.StatusConflict, nil)
+	do("POST", "/relays", `{"relay":{"uri":"http://turn.example.com"}}`, http.StatusBadRequest, nil)
+	do("POST", "/relays", `{"relay":{}}`, http.StatusBadRequest, nil)
+	do("POST", "/relays", `{}`, http.StatusBadRequest, nil)
+
+	got := &apiRelayResponse{}
+	do("GET", "/relays/"+id, "", http.StatusOK, got)
+
+	if got.Relay.URL != created.Relay.URL {
+		t.Errorf("Unexpected relay: %+v", got.Relay)
+	}
+
+	// Disabled relays are not signalled to peers
+	do("POST", "/relays/"+id, `{"relay":{"disabled":true}}`, http.StatusOK, got)
+
+	if !got.Relay.Disabled {
+		t.Error("Relay has not been disabled")
+	}
+
+	expectRelays("stun:stun.example.com:3478")
+
+	do("POST", "/relays/"+list.Relays[0].ID, `{"relay":{"uri":"turn:turn.example.com?secret=other"}}`, http.StatusConflict, nil)
+
+	// Changes are persisted
+	rs, err := NewRelayStore(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to load relay store: %s", err)
+	}
+
+	if rc, err := rs.Get(id); err != nil || !rc.Disabled {
+		t.Errorf("Relay has not been persisted: %+v %v", rc, err)
+	}
+
+	do("DELETE", "/relays/"+id, "", http.StatusNoContent, nil)
+	do("GET", "/relays/"+id, "", http.StatusNotFound, nil)
+	do("DELETE", "/relays/"+id, "", http.StatusNotFound, nil)
+	do("POST", "/relays/"+id, `{"relay":{"disabled":false}}`, http.StatusNotFound, nil)
+
+	if rs, err := NewRelayStore(path, nil); err != nil || len(rs.List()) != 1 {
+		t.Errorf("Deletion has not been persisted: %v", err)
+	}
+}
@@ -4,47 +4,88 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log/slog"
+	"maps"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/VILLASframework/signaling/pkg"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const sessionExpiryAge = time.Hour
+var (
+	errSessionClosed   = errors.New("session is closed")
+	errSessionNotFound = errors.New("session not found")
+)
 
+// Session is an actor: all of its mutable state including the state of its
+// peers is owned by the goroutine executing run().
+// Other goroutines must only access it via do() or the messages channel.
 type Session struct {
 	Name    string
 	Created time.Time
 
 	messages chan SignalingMessage
-
-	peers map[string]*Peer
-	mutex sync.RWMutex
-
-	lastPeerID atomic.Int32
+	commands chan func()
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	// Owned by run()
+	closing    bool
+	peers      map[string]*Peer
+	lastPeerID int32
+	protected  bool
+	labels     map[string]string
+	recorder   *recorder
+
+	// Expiry
+	lastActivity time.Time
+	ttl          time.Duration
+	expires      time.Time
+
+	// Span context of the current negotiation which is used as parent
+	// for messages which do not carry their own trace context.
+	negotiation trace.SpanContext
 
 	logger *slog.Logger
 }
 
 func NewSession(name string) *Session {
-	s := &Session{
-		Name:     name,
-		Created:  time.Now(),
-		peers:    map[string]*Peer{},
-		messages: make(chan SignalingMessage, 100),
+	now := time.Now()
 
-		logger: slog.With(slog.String("session", name)),
+	s := &Session{
+		Name:         name,
+		Created:      now,
+		lastActivity: now,
+		peers:        map[string]*Peer{},
+		messages:     make(chan SignalingMessage, 100),
+		commands:     make(chan func()),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+
+		logger: subsystemLogger("session").With(slog.String("session", name)),
 	}
 
 	s.logger.Info("Session opened")
 
+	if recordAll && recordDir != "" {
+		r, err := newRecorder(name)
+		if err != nil {
+			s.logger.Error("Failed to start recording", slog.Any("error", err))
+		}
+
+		s.recorder = r
+	}
+
 	go s.run()
 
 	metricSessionsCreated.Inc()
 
+	publishEvent(pkg.EventSessionCreated, name, nil)
+
 	return s
 }
 
@@ -61,6 +102,10 @@ func GetOrCreateSession(name string) (*Session, error) {
 
 	s := sessions[name]
 	if s == nil {
+		if draining.Load() {
+			return nil, errDraining
+		}
+
 		s = NewSession(name)
 		sessions[name] = s
 	}
@@ -68,154 +113,489 @@ func GetOrCreateSession(name string) (*Session, error) {
 	return s, nil
 }
 
+// GetSessions returns a snapshot of all currently active sessions.
+func GetSessions() []*Session {
+	sessionsMutex.RLock()
+	defer sessionsMutex.RUnlock()
+
+	ss := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		ss = append(ss, s)
+	}
+
+	return ss
+}
+
+// do executes f within the session goroutine and waits for its completion.
+func (s *Session) do(f func()) error {
+	finished := make(chan struct{})
+
+	select {
+	case s.commands <- func() {
+		defer close(finished)
+		f()
+	}:
+	case <-s.done:
+		return errSessionClosed
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-s.done:
+		return errSessionClosed
+	}
+}
+
+// Post queues a signaling message for forwarding to the other peers of the session.
+func (s *Session) Post(msg SignalingMessage) error {
+	select {
+	case s.messages <- msg:
+		return nil
+	case <-s.done:
+		return errSessionClosed
+	}
+}
+
 func (s *Session) RemovePeer(p *Peer) error {
-	if err := p.Close(); err != nil {
-		return fmt.Errorf("failed to close peer: %w", err)
+	var conn *Connection
+
+	if err := s.do(func() {
+		if s.removePeer(p) {
+			p.publishEvent(pkg.EventPeerRemoved)
+		}
+
+		conn = p.conn
+	}); err != nil {
+		return err
 	}
 
-	s.mutex.Lock()
-	delete(s.peers, p.Name)
-	s.mutex.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
 
 	return nil
 }
 
-func (s *Session) SendControlMessageToAllConnectedPeers() error {
-	msg := &pkg.SignalingMessage{
-		Control: &pkg.ControlMessage{},
+// addPeer registers a peer in the session. It must be called from within Session.run().
+func (s *Session) addPeer(p *Peer) {
+	if _, ok := s.peers[p.Name]; !ok {
+		metricRegisteredPeers.Inc()
 	}
 
-	s.mutex.RLock()
-	for _, p := range s.peers {
-		msg.Control.Peers = append(msg.Control.Peers, p.Marshal())
+	s.peers[p.Name] = p
+}
+
+// removePeer removes a peer from the session unless it has been replaced.
+// It must be called from within Session.run().
+func (s *Session) removePeer(p *Peer) bool {
+	if s.peers[p.Name] != p {
+		return false
 	}
-	s.mutex.RUnlock()
 
+	delete(s.peers, p.Name)
+
+	metricRegisteredPeers.Dec()
+
+	return true
+}
+
+func (s *Session) sendControlMessageToAllConnectedPeers() {
+	peers := []pkg.Peer{}
 	for _, p := range s.peers {
-		msg.Control.PeerID = p.id
+		peers = append(peers, p.marshal())
+	}
 
+	for _, p := range s.peers {
 		if p.conn == nil {
 			continue
 		}
 
-		if err := p.conn.WriteJSON(msg); err != nil {
-			return err
-		} else {
-			s.logger.Info("Send control message", slog.Any("msg", msg))
+		msg := SignalingMessage{
+			SignalingMessage: pkg.SignalingMessage{
+				Control: &pkg.ControlMessage{
+					PeerID: p.id,
+					Peers:  peers,
+				},
+			},
 		}
-	}
 
-	return nil
+		if p.conn.Send(msg) {
+			s.logger.Debug("Send control message", logMessage(&msg.SignalingMessage))
+			s.record(&msg, []string{p.Name})
+		}
+	}
 }
 
 func (s *Session) String() string {
 	return s.Name
 }
 
+// Close closes all connections of the session and stops its goroutine.
 func (s *Session) Close() error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	conns := []*Connection{}
 
-	for _, p := range s.peers {
-		if err := p.Close(); err != nil {
-			return err
+	if err := s.do(func() {
+		// Prevent new connections from being attached while the existing ones are closed
+		s.closing = true
+
+		for _, p := range s.peers {
+			if p.conn != nil {
+				conns = append(conns, p.conn)
+			}
 		}
+	}); err != nil && !errors.Is(err, errSessionClosed) {
+		return err
 	}
 
-	return nil
+	var errs []error
+	for _, c := range conns {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	<-s.done
+
+	deleteSessionMetrics(s.Name)
+
+	return errors.Join(errs...)
 }
 
 func (s *Session) run() {
-	for msg := range s.messages {
-		s.handleMessage(msg)
+	defer close(s.done)
+
+	// Connections which are detached after the session has been closed are not accounted anymore
+	defer func() {
+		for _, p := range s.peers {
+			if p.conn != nil {
+				metricConnectedPeers.Dec()
+				metricSessionConnectedPeers.WithLabelValues(sessionLabel(s.Name)).Dec()
+			}
+
+			metricRegisteredPeers.Dec()
+		}
+	}()
+
+	defer func() {
+		if s.recorder != nil {
+			if err := s.recorder.Close(); err != nil {
+				s.logger.Error("Failed to close recording", slog.Any("error", err))
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+
+		case f := <-s.commands:
+			f()
+
+		case msg := <-s.messages:
+			s.handleMessage(msg)
+		}
 	}
 }
 
 func (s *Session) handleMessage(msg SignalingMessage) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
 	msg.CollectMetrics()
 
+	isOffer := msg.Description != nil && msg.Description.Type == "offer"
+
+	ctx := extractTraceContext(context.Background(), &msg.SignalingMessage)
+	if !trace.SpanContextFromContext(ctx).IsValid() && !isOffer && s.negotiation.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, s.negotiation)
+	}
+
+	ctx, span := tracer.Start(ctx, "forward "+messageType(&msg.SignalingMessage),
+		trace.WithTimestamp(msg.Received),
+		trace.WithSpanKind(trace.SpanKindServer),
+		sessionAttributes(s.Name, msg.Sender.Name))
+	defer span.End()
+
+	if isOffer || !s.negotiation.IsValid() {
+		s.negotiation = span.SpanContext()
+	}
+
+	injectTraceContext(ctx, &msg.SignalingMessage)
+	msg.ctx = ctx
+
+	s.touch()
+
+	recipients := []string{}
 	for _, p := range s.peers {
 		if msg.Sender == p || p.conn == nil {
 			continue
 		}
 
-		p.conn.messages <- msg
+		if p.conn.Send(msg) {
+			recipients = append(recipients, p.Name)
+		}
+	}
+
+	s.record(&msg, recipients)
+}
+
+// attach binds an established connection to a peer.
+func (s *Session) attach(p *Peer, c *Connection) error {
+	var err error
+
+	if doErr := s.do(func() {
+		if s.closing {
+			err = errSessionClosed
+			return
+		} else if p.conn != nil {
+			err = errPeerConnected
+			return
+		}
+
+		s.lastPeerID++
+
+		p.id = s.lastPeerID
+		p.conn = c
+		p.connected = time.Now()
+		p.userAgent = c.userAgent
+		p.remote = c.RemoteAddr().String()
+		s.addPeer(p)
+
+		s.touch()
+
+		sess := sessionLabel(s.Name)
+		if p.connects > 0 {
+			metricReconnects.WithLabelValues(sess, peerLabel(p.Name)).Inc()
+		}
+		p.connects++
+
+		metricConnectedPeers.Inc()
+		metricSessionConnectedPeers.WithLabelValues(sess).Inc()
+
+		p.publishEvent(pkg.EventPeerConnected)
+
+		if c.signals != nil {
+			s.record(c.signals, nil)
+		}
+
+		s.sendControlMessageToAllConnectedPeers()
+
+		// Peers which join existing sessions during a drain are asked to leave as well
+		if draining.Load() {
+			s.sendGoingAway(p)
+		}
+	}); doErr != nil {
+		return doErr
 	}
+
+	return err
 }
 
-func (s *Session) Marshal() pkg.Session {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+// detach unbinds a closed connection from its peer.
+func (s *Session) detach(p *Peer, c *Connection) error {
+	return s.do(func() {
+		if p.conn != c {
+			return
+		}
+
+		sess := sessionLabel(s.Name)
+		metricConnectedPeers.Dec()
+		metricSessionConnectedPeers.WithLabelValues(sess).Dec()
+		metricConnectionDuration.WithLabelValues(sess).Observe(time.Since(p.connected).Seconds())
+		metricDisconnects.WithLabelValues(sess, peerLabel(p.Name), c.reason).Inc()
+
+		p.conn = nil
+		p.connected = time.Time{}
+		p.remote = ""
+
+		s.touch()
 
+		p.publishEvent(pkg.EventPeerDisconnected)
+
+		// Remove peer if it does not have any signal metadata associated
+		if p.signals == nil && s.removePeer(p) {
+			p.publishEvent(pkg.EventPeerRemoved)
+		}
+
+		s.sendControlMessageToAllConnectedPeers()
+	})
+}
+
+func (s *Session) marshal() pkg.Session {
 	conns := []pkg.Peer{}
 	for _, p := range s.peers {
-		conns = append(conns, p.Marshal())
+		conns = append(conns, p.marshal())
 	}
 
-	return pkg.Session{
-		Name:    s.Name,
-		Created: s.Created,
-		Peers:   conns,
+	ps := pkg.Session{
+		Name:         s.Name,
+		Created:      s.Created,
+		LastActivity: s.lastActivity,
+		Protected:    s.protected,
+		Labels:       maps.Clone(s.labels),
+		Recording:    s.recorder != nil,
+		Peers:        conns,
 	}
+
+	if !s.expires.IsZero() {
+		expires := s.expires
+		ps.Expires = &expires
+	}
+
+	return ps
 }
 
-func (s *Session) GetPeer(name string) (p *Peer) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *Session) Marshal() (ps pkg.Session) {
+	if err := s.do(func() {
+		ps = s.marshal()
+	}); err != nil {
+		return pkg.Session{
+			Name:    s.Name,
+			Created: s.Created,
+			Peers:   []pkg.Peer{},
+		}
+	}
 
-	return s.peers[name]
+	return ps
 }
 
-func (s *Session) GetOrCreatePeer(name string) (p *Peer, err error) {
-	var ok bool
+// SetProtected requires peers to join the session via signed join URLs.
+// Connected peers which did not join via a signed join URL are disconnected.
+func (s *Session) SetProtected(protected bool) error {
+	conns := []*Connection{}
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if err := s.do(func() {
+		s.protected = protected
+		if !protected {
+			return
+		}
 
-	p, ok = s.peers[name]
-	if !ok {
-		p, err = s.NewPeer(name)
-		if err != nil {
-			return nil, err
+		for _, p := range s.peers {
+			if p.conn != nil && !p.conn.signedJoin {
+				conns = append(conns, p.conn)
+			}
 		}
+	}); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range conns {
+		c.logger.Info("Disconnecting peer which did not join via a signed join URL")
 
-		s.peers[p.Name] = p
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	return p, nil
+	return errors.Join(errs...)
 }
 
-func closeSessions() {
-	sessionsMutex.Lock()
-	defer sessionsMutex.Unlock()
+// SetLabels replaces the labels of the session which are used for relay selection.
+func (s *Session) SetLabels(labels map[string]string) error {
+	return s.do(func() {
+		s.labels = maps.Clone(labels)
+	})
+}
 
-	for name, s := range sessions {
-		if err := s.Close(); err != nil {
-			slog.Error("Failed to close session", slog.Any("error", err))
+func (s *Session) Labels() (labels map[string]string) {
+	s.do(func() { //nolint:errcheck
+		labels = maps.Clone(s.labels)
+	})
+
+	return labels
+}
+
+func (s *Session) IsProtected() (protected bool) {
+	if err := s.do(func() {
+		protected = s.protected
+	}); err != nil {
+		// Fail closed for sessions which are closing
+		return true
+	}
+
+	return protected
+}
+
+// PeerCount returns the number of registered and connected peers.
+func (s *Session) PeerCount() (registered, connected int) {
+	s.do(func() { //nolint:errcheck
+		registered = len(s.peers)
+
+		for _, p := range s.peers {
+			if p.conn != nil {
+				connected++
+			}
 		}
+	})
+
+	return registered, connected
+}
+
+func (s *Session) GetPeer(name string) (p *Peer) {
+	s.do(func() { //nolint:errcheck
+		p = s.peers[name]
+	})
+
+	return p
+}
 
-		delete(sessions, name)
+func (s *Session) GetOrCreatePeer(name string) (p *Peer, err error) {
+	if doErr := s.do(func() {
+		var ok bool
+
+		p, ok = s.peers[name]
+		if !ok {
+			p, err = s.NewPeer(name)
+			if err != nil {
+				return
+			}
+
+			s.addPeer(p)
+			s.touch()
+
+			p.publishEvent(pkg.EventPeerRegistered)
+		}
+	}); doErr != nil {
+		return nil, doErr
 	}
+
+	return p, err
 }
 
-func expireSessions() {
+// DeleteSession closes a session and disconnects all its peers.
+func DeleteSession(name string) error {
 	sessionsMutex.Lock()
-	defer sessionsMutex.Unlock()
 
-	for name, session := range sessions {
-		if len(session.peers) == 0 && time.Since(session.Created) > sessionExpiryAge {
-			slog.Debug("Removing stale session",
-				slog.String("session", name),
-				slog.Time("created", session.Created))
+	s, ok := sessions[name]
+	if !ok {
+		sessionsMutex.Unlock()
+		return errSessionNotFound
+	}
 
-			if err := session.Close(); err != nil {
-				slog.Error("Failed to close session", slog.Any("error", err))
-			}
+	delete(sessions, name)
+	sessionsMutex.Unlock()
 
-			delete(sessions, name)
+	// Closing waits for the connections and must not block other sessions
+	err := s.Close()
+
+	publishEvent(pkg.EventSessionDeleted, name, nil)
+
+	return err
+}
+
+func closeSessions() {
+	sessionsMutex.Lock()
+	ss := sessions
+	sessions = map[string]*Session{}
+	sessionsMutex.Unlock()
+
+	for _, s := range ss {
+		if err := s.Close(); err != nil {
+			slog.Error("Failed to close session", slog.Any("error", err))
 		}
 	}
 }
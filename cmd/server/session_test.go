@@ -0,0 +1,326 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	r := mux.NewRouter()
+	a := newAPIRouter(r)
+
+	a.Path("/sessions").
+		Methods("GET").
+		HandlerFunc(handleAPISessions)
+
+	a.Path("/session/{session}").
+		Methods("GET", "POST", "DELETE").
+		HandlerFunc(handleAPISession)
+
+	r.Path("/{session}/{peer}").
+		HandlerFunc(handleWebsocket)
+
+	srv := httptest.NewServer(r)
+
+	t.Cleanup(func() {
+		closeSessions()
+		srv.Close()
+	})
+
+	return srv
+}
+
+// dialPeer connects a peer and performs the handshake.
+func dialPeer(srv *httptest.Server, session, peer string) (*websocket.Conn, error) {
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/" + session + "/" + peer
+
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.WriteJSON(&pkg.SignalingMessage{}); err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	msg := &pkg.SignalingMessage{}
+	if err := conn.ReadJSON(msg); err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func candidateMessage(size int) *pkg.SignalingMessage {
+	return &pkg.SignalingMessage{
+		Candidate: &pkg.CandidateMessage{
+			Spd: "candidate:" + strings.Repeat("x", size),
+		},
+	}
+}
+
+// waitFor polls cond until it returns true or the timeout expires.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func connectedPeers() (connected int) {
+	for _, s := range GetSessions() {
+		_, c := s.PeerCount()
+		connected += c
+	}
+
+	return connected
+}
+
+// TestSessionStress connects and disconnects hundreds of peers which forward messages
+// while sessions are concurrently deleted and queried via the API.
+// It is meant to be run with -race.
+func TestSessionStress(t *testing.T) {
+	srv := newTestServer(t)
+
+	const (
+		numSessions = 10
+		numPeers    = 300
+		numMessages = 10
+	)
+
+	wg := sync.WaitGroup{}
+	stop := make(chan struct{})
+
+	for i := 0; i < numPeers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sess := fmt.Sprintf("session-%d", i%numSessions)
+
+			// Failures are expected when the session is deleted concurrently
+			conn, err := dialPeer(srv, sess, fmt.Sprintf("peer-%d", i))
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			go func() {
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			for j := 0; j < numMessages; j++ {
+				if err := conn.WriteJSON(candidateMessage(32)); err != nil {
+					return
+				}
+			}
+
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second)) //nolint:errcheck
+		}()
+	}
+
+	apiWG := sync.WaitGroup{}
+	apiWG.Add(2)
+
+	go func() {
+		defer apiWG.Done()
+
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/session/session-%d", srv.URL, i%numSessions), nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	go func() {
+		defer apiWG.Done()
+
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if resp, err := http.Get(srv.URL + "/api/v1/sessions"); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	apiWG.Wait()
+
+	// All connections must eventually be detached from their sessions
+	waitFor(t, 10*time.Second, func() bool {
+		return connectedPeers() == 0
+	})
+}
+
+// TestSlowConsumer checks that a peer which does not read its messages is disconnected
+// without stalling the session.
+func TestSlowConsumer(t *testing.T) {
+	srv := newTestServer(t)
+
+	sender, err := dialPeer(srv, "slow", "sender")
+	if err != nil {
+		t.Fatalf("Failed to connect sender: %s", err)
+	}
+	defer sender.Close()
+
+	go func() {
+		for {
+			if _, _, err := sender.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The slow peer never reads
+	slow, err := dialPeer(srv, "slow", "receiver")
+	if err != nil {
+		t.Fatalf("Failed to connect receiver: %s", err)
+	}
+	defer slow.Close()
+
+	sess := GetSession("slow")
+
+	waitFor(t, 5*time.Second, func() bool {
+		_, connected := sess.PeerCount()
+		return connected == 2
+	})
+
+	// Enough data to fill the socket buffers as well as the send buffer of the slow peer
+	msg := candidateMessage(maxMessageSize - 100)
+	for i := 0; i < 10000; i++ {
+		if _, connected := sess.PeerCount(); connected < 2 {
+			break
+		}
+
+		if err := sender.WriteJSON(msg); err != nil {
+			t.Fatalf("Failed to send message: %s", err)
+		}
+	}
+
+	waitFor(t, 5*time.Second, func() bool {
+		return sess.GetPeer("receiver") == nil
+	})
+
+	if !sess.GetPeer("sender").IsConnected() {
+		t.Fatal("Sender has been disconnected")
+	}
+}
+
+// TestCloseSessionWhileAttaching checks that a connection which is attached
+// while its session is being closed is not leaked.
+func TestCloseSessionWhileAttaching(t *testing.T) {
+	srv := newTestServer(t)
+
+	// The existing peer does not answer the closing handshake which keeps Close() busy
+	existing, err := dialPeer(srv, "closing", "existing")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer existing.Close()
+
+	sess := GetSession("closing")
+
+	ready := make(chan struct{})
+	attach := make(chan struct{})
+
+	// Holds on to the session while it is deleted before connecting the peer
+	lateSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := sess.GetOrCreatePeer("late")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		close(ready)
+		<-attach
+
+		if err := p.Connect(w, r); err != nil {
+			t.Logf("Failed to connect: %s", err)
+		}
+	}))
+	defer lateSrv.Close()
+
+	var conn *websocket.Conn
+	dialed := make(chan error, 1)
+
+	go func() {
+		var err error
+		conn, err = dialPeer(lateSrv, "closing", "late")
+		dialed <- err
+	}()
+
+	<-ready
+
+	deleted := make(chan error, 1)
+	go func() {
+		deleted <- DeleteSession("closing")
+	}()
+
+	// Wait until Close() is waiting for the existing connection
+	time.Sleep(200 * time.Millisecond)
+	close(attach)
+
+	if err := <-deleted; err != nil {
+		t.Fatalf("Failed to delete session: %s", err)
+	}
+
+	if err := <-dialed; err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second)) //nolint:errcheck
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Fatal("Connection of closed session has not been closed")
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/VILLASframework/signaling/cmd/server"
+
+var (
+	// Flags
+	otlpEndpoint    string
+	otlpSampleRatio float64
+
+	tracer     = otel.Tracer(tracerName)
+	propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// setupTracing configures the OTLP trace exporter if an endpoint has been provided.
+// The returned function flushes and stops the exporter.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	if otlpEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("villas-signaling"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(otlpSampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// tracingMiddleware creates a server span for each API request named by its route template.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewMiddleware("api",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					return r.Method + " " + tpl
+				}
+			}
+
+			return r.Method
+		}),
+	)(next)
+}
+
+// extractTraceContext returns a context carrying the trace context embedded in a signaling message.
+func extractTraceContext(ctx context.Context, msg *pkg.SignalingMessage) context.Context {
+	if len(msg.Trace) == 0 {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, propagation.MapCarrier(msg.Trace))
+}
+
+// injectTraceContext embeds the trace context of ctx into a signaling message.
+func injectTraceContext(ctx context.Context, msg *pkg.SignalingMessage) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	if len(carrier) > 0 {
+		msg.Trace = carrier
+	} else {
+		msg.Trace = nil
+	}
+}
+
+func sessionAttributes(sess, peer string) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("signaling.session", sess),
+		attribute.String("signaling.peer", peer),
+	)
+}
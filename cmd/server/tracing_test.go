@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testSpans     *tracetest.InMemoryExporter
+	testSpansOnce sync.Once
+)
+
+// setupTestTracing installs a tracer provider which records all spans in memory.
+// The global tracer provider can only be replaced once.
+func setupTestTracing() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	testSpansOnce.Do(func() {
+		testSpans = tracetest.NewInMemoryExporter()
+
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(testSpans)))
+	})
+
+	return testSpans, otel.GetTracerProvider().(*sdktrace.TracerProvider)
+}
+
+// readSignalingMessage reads the next message which is not a control message.
+func readSignalingMessage(t *testing.T, conn *websocket.Conn) *pkg.SignalingMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+
+	for {
+		msg := &pkg.SignalingMessage{}
+		if err := conn.ReadJSON(msg); err != nil {
+			t.Fatalf("Failed to read message: %s", err)
+		}
+
+		if msg.Control == nil {
+			return msg
+		}
+	}
+}
+
+func traceID(t *testing.T, msg *pkg.SignalingMessage) trace.TraceID {
+	t.Helper()
+
+	ctx := propagator.Extract(context.Background(), propagation.MapCarrier(msg.Trace))
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		t.Fatalf("Message carries no trace context: %s", msg)
+	}
+
+	return sc.TraceID()
+}
+
+// TestTracePropagation checks that the trace context of an offer is propagated
+// to the answer and the candidates of the same negotiation which do not carry their own.
+func TestTracePropagation(t *testing.T) {
+	spans, tp := setupTestTracing()
+	spans.Reset()
+
+	srv := newTestServer(t)
+
+	a, err := dialPeer(srv, "tracing", "a")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer a.Close()
+
+	b, err := dialPeer(srv, "tracing", "b")
+	if err != nil {
+		t.Fatalf("Failed to connect peer: %s", err)
+	}
+	defer b.Close()
+
+	// The offering peer starts the trace
+	ctx, span := tp.Tracer("test").Start(context.Background(), "negotiate")
+	defer span.End()
+
+	offer := &pkg.SignalingMessage{
+		Description: &pkg.DescriptionMessage{
+			Type: "offer",
+			Spd:  "v=0",
+		},
+		Trace: map[string]string{},
+	}
+
+	propagator.Inject(ctx, propagation.MapCarrier(offer.Trace))
+
+	want := span.SpanContext().TraceID()
+
+	if err := a.WriteJSON(offer); err != nil {
+		t.Fatalf("Failed to send offer: %s", err)
+	}
+
+	if msg := readSignalingMessage(t, b); msg.Description == nil || traceID(t, msg) != want {
+		t.Fatalf("Offer has not been forwarded with its trace context: %s", msg)
+	}
+
+	// The answer and candidates do not carry a trace context
+	answer := &pkg.SignalingMessage{
+		Description: &pkg.DescriptionMessage{
+			Type: "answer",
+			Spd:  "v=0",
+		},
+	}
+
+	if err := b.WriteJSON(answer); err != nil {
+		t.Fatalf("Failed to send answer: %s", err)
+	}
+
+	if msg := readSignalingMessage(t, a); msg.Description == nil || traceID(t, msg) != want {
+		t.Fatalf("Answer has not been forwarded within the trace of the offer: %s", msg)
+	}
+
+	if err := b.WriteJSON(candidateMessage(16)); err != nil {
+		t.Fatalf("Failed to send candidate: %s", err)
+	}
+
+	if msg := readSignalingMessage(t, a); msg.Candidate == nil || traceID(t, msg) != want {
+		t.Fatalf("Candidate has not been forwarded within the trace of the offer: %s", msg)
+	}
+
+	// The server records spans for forwarding and sending each message.
+	// The send spans end after the message has been written.
+	wantSpans := []string{"forward description", "send description", "forward candidate", "send candidate"}
+
+	waitFor(t, 5*time.Second, func() bool {
+		names := map[string]bool{}
+		for _, s := range spans.GetSpans() {
+			if s.SpanContext.TraceID() == want {
+				names[s.Name] = true
+			}
+		}
+
+		for _, name := range wantSpans {
+			if !names[name] {
+				return false
+			}
+		}
+
+		return true
+	})
+}
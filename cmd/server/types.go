@@ -3,12 +3,41 @@
 
 package main
 
-import "github.com/VILLASframework/signaling/pkg"
+import (
+	"context"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
 
 type SignalingMessage struct {
 	pkg.SignalingMessage
 
-	Sender *Peer
+	Sender   *Peer
+	Received time.Time
+
+	// Context of the forwarding span
+	ctx context.Context
+}
+
+// messageType returns a label describing the content of a signaling message.
+func messageType(msg *pkg.SignalingMessage) string {
+	switch {
+	case msg.Candidate != nil:
+		return "candidate"
+	case msg.Description != nil:
+		return "description"
+	case msg.Control != nil:
+		return "control"
+	case msg.Signals != nil:
+		return "signals"
+	case msg.Relays != nil:
+		return "relays"
+	case msg.GoingAway != nil:
+		return "going_away"
+	default:
+		return "unknown"
+	}
 }
 
 func (msg *SignalingMessage) CollectMetrics() {
@@ -0,0 +1,290 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/google/uuid"
+)
+
+const (
+	// Number of webhook deliveries kept for inspection via the API.
+	webhookDeliveryLogSize = 100
+
+	// Time allowed for a single webhook request.
+	webhookTimeout = 10 * time.Second
+
+	// Maximum number of concurrent requests per webhook.
+	webhookConcurrency = 4
+)
+
+type Webhook struct {
+	URL    string
+	Events []pkg.EventType
+
+	events   chan pkg.Event
+	retries  chan *webhookDelivery
+	inflight chan struct{}
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+type webhooks []*Webhook
+
+func (w *webhooks) String() string {
+	strs := []string{}
+
+	for _, wh := range *w {
+		strs = append(strs, wh.URL)
+	}
+
+	return strings.Join(strs, ",")
+}
+
+// Set parses a webhook URL.
+// The optional "events" query parameter restricts the webhook to a comma-separated list of event types.
+func (w *webhooks) Set(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	wh := &Webhook{}
+
+	q := u.Query()
+	if evs := q.Get("events"); evs != "" {
+		for _, ev := range strings.Split(evs, ",") {
+			wh.Events = append(wh.Events, pkg.EventType(ev))
+		}
+
+		q.Del("events")
+		u.RawQuery = q.Encode()
+	}
+
+	wh.URL = u.String()
+
+	*w = append(*w, wh)
+
+	return nil
+}
+
+type webhookDeliveryLog struct {
+	deliveries []pkg.WebhookDelivery
+	mutex      sync.RWMutex
+}
+
+func (l *webhookDeliveryLog) Add(d pkg.WebhookDelivery) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.deliveries = append(l.deliveries, d)
+	if len(l.deliveries) > webhookDeliveryLogSize {
+		l.deliveries = l.deliveries[len(l.deliveries)-webhookDeliveryLogSize:]
+	}
+}
+
+func (l *webhookDeliveryLog) List() []pkg.WebhookDelivery {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return append([]pkg.WebhookDelivery{}, l.deliveries...)
+}
+
+var (
+	// Flags
+	hooks          webhooks
+	webhookSecret  string
+	webhookRetries int
+
+	webhookBackoff    = pkg.DefaultExponentialBackoff
+	webhookDeliveries = &webhookDeliveryLog{}
+)
+
+// webhookDelivery is the state of an event delivery across its attempts.
+type webhookDelivery struct {
+	id      string
+	event   pkg.Event
+	payload []byte
+	attempt int
+	backoff pkg.ExponentialBackoff
+}
+
+func startWebhooks() {
+	for _, wh := range hooks {
+		wh.start()
+	}
+}
+
+func (wh *Webhook) start() {
+	wh.events = make(chan pkg.Event, 100)
+	wh.retries = make(chan *webhookDelivery)
+	wh.inflight = make(chan struct{}, webhookConcurrency)
+	wh.client = &http.Client{
+		Timeout: webhookTimeout,
+	}
+	wh.logger = subsystemLogger("webhook").With(slog.String("webhook", wh.URL))
+
+	SubscribeEvents(wh.name(), wh.events)
+
+	go wh.run()
+}
+
+// name identifies the webhook in metrics without exposing credentials in its URL.
+func (wh *Webhook) name() string {
+	u, err := url.Parse(wh.URL)
+	if err != nil {
+		return "webhook"
+	}
+
+	return "webhook:" + u.Scheme + "://" + u.Host + u.Path
+}
+
+func (wh *Webhook) run() {
+	for {
+		select {
+		case ev := <-wh.events:
+			if len(wh.Events) > 0 && !slices.Contains(wh.Events, ev.Type) {
+				continue
+			}
+
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				wh.logger.Error("Failed to encode event", slog.Any("error", err))
+				continue
+			}
+
+			d := &webhookDelivery{
+				id:      uuid.New().String(),
+				event:   ev,
+				payload: payload,
+				backoff: webhookBackoff,
+			}
+
+			d.backoff.Reset()
+
+			wh.deliver(d)
+
+		case d := <-wh.retries:
+			wh.deliver(d)
+		}
+	}
+}
+
+// deliver starts the next attempt of a delivery.
+// Up to webhookConcurrency attempts are performed concurrently
+// so that a slow endpoint does not stall the event queue.
+// Failed attempts are retried with an exponential backoff.
+func (wh *Webhook) deliver(d *webhookDelivery) {
+	wh.inflight <- struct{}{}
+
+	go func() {
+		defer func() { <-wh.inflight }()
+
+		d.attempt++
+
+		rec := pkg.WebhookDelivery{
+			ID:      d.id,
+			Event:   d.event,
+			URL:     wh.URL,
+			Attempt: d.attempt,
+			Time:    time.Now(),
+		}
+
+		status, err := wh.post(d.id, d.payload)
+
+		rec.Duration = time.Since(rec.Time)
+		rec.Status = status
+		if err != nil {
+			rec.Error = err.Error()
+		}
+
+		webhookDeliveries.Add(rec)
+
+		if err == nil {
+			wh.logger.Debug("Delivered event",
+				slog.String("type", string(d.event.Type)),
+				slog.Int("attempt", d.attempt))
+			return
+		}
+
+		if d.attempt > webhookRetries {
+			wh.logger.Error("Failed to deliver event",
+				slog.String("type", string(d.event.Type)),
+				slog.Int("attempts", d.attempt),
+				slog.Any("error", err))
+			return
+		}
+
+		wait := d.backoff.Next()
+
+		wh.logger.Warn("Failed to deliver event. Retrying...",
+			slog.String("type", string(d.event.Type)),
+			slog.Int("attempt", d.attempt),
+			slog.Duration("wait", wait),
+			slog.Any("error", err))
+
+		time.AfterFunc(wait, func() {
+			wh.retries <- d
+		})
+	}()
+}
+
+func (wh *Webhook) post(id string, payload []byte) (int, error) {
+	req, err := http.NewRequest("POST", wh.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "villas-signaling")
+	req.Header.Set("X-Signaling-Delivery", id)
+
+	if webhookSecret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+		req.Header.Set("X-Signaling-Timestamp", ts)
+		req.Header.Set("X-Signaling-Signature", "sha256="+signPayload(webhookSecret, ts, payload))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signPayload returns the hex-encoded HMAC-SHA256 of the timestamp and payload joined by a dot.
+// Receivers should reject deliveries with old timestamps to prevent replays.
+func signPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
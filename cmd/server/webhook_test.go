@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type webhookRequest struct {
+	event     pkg.Event
+	delivery  string
+	timestamp string
+	signature string
+	valid     bool
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	webhookSecret = "s3cret"
+	webhookRetries = 2
+	webhookBackoff = pkg.ExponentialBackoff{Factor: 1}
+	t.Cleanup(func() {
+		webhookSecret = ""
+		webhookBackoff = pkg.DefaultExponentialBackoff
+	})
+
+	requests := make(chan webhookRequest, 10)
+	failures := atomic.Int32{}
+	failures.Store(1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read body: %s", err)
+			return
+		}
+
+		req := webhookRequest{
+			delivery:  r.Header.Get("X-Signaling-Delivery"),
+			timestamp: r.Header.Get("X-Signaling-Timestamp"),
+			signature: r.Header.Get("X-Signaling-Signature"),
+		}
+
+		req.valid = req.signature == "sha256="+signPayload(webhookSecret, req.timestamp, payload)
+
+		if err := json.Unmarshal(payload, &req.event); err != nil {
+			t.Errorf("Failed to decode event: %s", err)
+		}
+
+		requests <- req
+
+		// Fail the first attempt
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	wh := &webhooks{}
+	if err := wh.Set(srv.URL + "/hook?events=" + string(pkg.EventSessionCreated)); err != nil {
+		t.Fatalf("Failed to parse webhook: %s", err)
+	}
+
+	(*wh)[0].start()
+	defer UnsubscribeEvents((*wh)[0].events)
+
+	// Filtered by the webhook
+	publishEvent(pkg.EventSessionDeleted, "ignored", nil)
+	publishEvent(pkg.EventSessionCreated, "test", nil)
+
+	var first webhookRequest
+	for attempt := 1; attempt <= 2; attempt++ {
+		select {
+		case req := <-requests:
+			if req.event.Type != pkg.EventSessionCreated || req.event.Session != "test" {
+				t.Fatalf("Unexpected event: %+v", req.event)
+			}
+
+			if !req.valid {
+				t.Fatalf("Invalid signature: %s", req.signature)
+			}
+
+			if ts, err := strconv.ParseInt(req.timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+				t.Fatalf("Invalid timestamp: %s", req.timestamp)
+			}
+
+			if attempt == 1 {
+				first = req
+			} else if req.delivery != first.delivery {
+				t.Fatalf("Retry has a different delivery ID: %s != %s", req.delivery, first.delivery)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for attempt %d", attempt)
+		}
+	}
+
+	select {
+	case req := <-requests:
+		t.Fatalf("Unexpected request: %+v", req.event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Both attempts are recorded in the delivery log
+	waitFor(t, time.Second, func() bool {
+		n := 0
+		for _, d := range webhookDeliveries.List() {
+			if d.ID == first.delivery {
+				n++
+			}
+		}
+
+		return n == 2
+	})
+}
+
+func TestSignPayloadCoversTimestamp(t *testing.T) {
+	payload := []byte(`{"type":"session.created"}`)
+
+	if signPayload("secret", "1000", payload) == signPayload("secret", "2000", payload) {
+		t.Fatal("Signature does not depend on the timestamp")
+	}
+}
+
+func TestDroppedEvents(t *testing.T) {
+	ch := make(chan pkg.Event)
+	SubscribeEvents("test", ch)
+	defer UnsubscribeEvents(ch)
+
+	before := testutil.ToFloat64(metricEventsDropped.WithLabelValues("test"))
+
+	publishEvent(pkg.EventSessionCreated, "dropped", nil)
+
+	if after := testutil.ToFloat64(metricEventsDropped.WithLabelValues("test")); after != before+1 {
+		t.Fatalf("Dropped event has not been counted: %v", after-before)
+	}
+}
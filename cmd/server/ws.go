@@ -4,11 +4,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func handleWebsocket(w http.ResponseWriter, r *http.Request) {
@@ -20,20 +24,41 @@ func handleWebsocket(w http.ResponseWriter, r *http.Request) {
 		peerName = uuid.New().String()
 	}
 
+	ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	_, span := tracer.Start(ctx, "websocket connect",
+		trace.WithSpanKind(trace.SpanKindServer),
+		sessionAttributes(sessName, peerName))
+	defer span.End()
+
+	fail := func(code int, err error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		writeError(w, code, err)
+	}
+
 	sess, err := GetOrCreateSession(sessName)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create session: %w", err))
+	if errors.Is(err, errDraining) {
+		fail(http.StatusServiceUnavailable, fmt.Errorf("failed to create session: %w", err))
+		return
+	} else if err != nil {
+		fail(http.StatusInternalServerError, fmt.Errorf("failed to create session: %w", err))
+		return
+	}
+
+	if err := checkJoin(r, sess, peerName); err != nil {
+		fail(http.StatusForbidden, fmt.Errorf("failed to join session: %w", err))
 		return
 	}
 
 	peer, err := sess.GetOrCreatePeer(peerName)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to create peer: %w", err))
+		fail(http.StatusInternalServerError, fmt.Errorf("failed to create peer: %w", err))
 		return
 	}
 
 	if err := peer.Connect(w, r); err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to connect peer: %w", err))
+		fail(http.StatusInternalServerError, fmt.Errorf("failed to connect peer: %w", err))
 		return
 	}
 }
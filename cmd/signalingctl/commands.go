@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func runSessions(ctx context.Context) error {
+	sessions, err := client.Sessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	return out.Sessions(sessions)
+}
+
+func runSession(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: session get|delete SESSION")
+	}
+
+	switch cmd, name := args[0], args[1]; cmd {
+	case "get":
+		sess, err := client.Session(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		return out.Session(sess)
+
+	case "delete":
+		return client.DeleteSession(ctx, name)
+
+	default:
+		return fmt.Errorf("unknown session command: %s", cmd)
+	}
+}
+
+func runPeers(ctx context.Context, session string) error {
+	sess, err := client.Session(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	return out.Peers(sess.Peers)
+}
+
+func runPeer(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: peer get|delete|register SESSION PEER")
+	}
+
+	cmd, args := args[0], args[1:]
+	if cmd == "register" {
+		return runPeerRegister(ctx, args)
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: peer %s SESSION PEER", cmd)
+	}
+
+	switch cmd {
+	case "get":
+		peer, err := client.Peer(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		return out.Peer(peer)
+
+	case "delete":
+		return client.DeletePeer(ctx, args[0], args[1])
+
+	default:
+		return fmt.Errorf("unknown peer command: %s", cmd)
+	}
+}
+
+func runPeerRegister(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("peer register", flag.ExitOnError)
+	file := fs.String("signals", "", "JSON file with a list of signals or a VILLASnode config")
+	node := fs.String("node", "", "Name of the node in a VILLASnode config (defaults to the only WebRTC node)")
+	dir := fs.String("direction", "out", "Direction of the signals taken from a VILLASnode config (in, out)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return errors.New("usage: peer register [-signals FILE] [-node NAME] [-direction in|out] SESSION PEER")
+	}
+
+	var signals []pkg.Signal
+	if *file != "" {
+		var err error
+		if signals, err = loadSignals(*file, *node, *dir); err != nil {
+			return err
+		}
+	}
+
+	peer, err := client.RegisterPeer(ctx, fs.Arg(0), fs.Arg(1), signals)
+	if err != nil {
+		return err
+	}
+
+	return out.Peer(peer)
+}
+
+func runEvents(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	session := fs.String("session", "", "Only show events of this session")
+
+	types := []pkg.EventType{}
+	fs.Func("type", "Only show events of this type (can be specified multiple times)", func(s string) error {
+		types = append(types, pkg.EventType(s))
+		return nil
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	events, errs, err := client.Events(ctx, *session, types)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				select {
+				case err := <-errs:
+					return err
+				default:
+					return ctx.Err()
+				}
+			}
+
+			if err := out.Event(ev); err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func runJoin(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	ttl := fs.Duration("ttl", 0, "Validity of the join URL (defaults to the server setting)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return errors.New("usage: join [-ttl DURATION] SESSION PEER")
+	}
+
+	join, err := client.JoinURL(ctx, fs.Arg(0), fs.Arg(1), *ttl)
+	if err != nil {
+		return err
+	}
+
+	if out.json {
+		return out.JSON(join)
+	}
+
+	fmt.Fprintf(out.w, "URL:     %s\n", join.URL)
+	fmt.Fprintf(out.w, "Expires: %s\n", formatTime(join.Expires))
+
+	return nil
+}
+
+func runTURN(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("turn", flag.ExitOnError)
+	username := fs.String("username", "", "TURN username (defaults to the authenticated principal)")
+	hint := fs.String("hint", "", "Relay group hint")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	creds, err := client.TURNCredentials(ctx, *username, *hint)
+	if err != nil {
+		return err
+	}
+
+	if out.json {
+		return out.JSON(creds)
+	}
+
+	fmt.Fprintf(out.w, "Username: %s\n", creds.Username)
+	fmt.Fprintf(out.w, "Password: %s\n", creds.Password)
+	fmt.Fprintf(out.w, "TTL:      %s\n", time.Duration(creds.TTL)*time.Second)
+
+	for _, uri := range creds.URIs {
+		fmt.Fprintf(out.w, "URI:      %s\n", uri)
+	}
+
+	return nil
+}
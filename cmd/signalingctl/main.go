@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+const usage = `usage: %s [-url URL] [-token TOKEN] [-o table|json] <command> [args]
+
+commands:
+  sessions                                        List sessions
+  session get SESSION                             Show a session and its peers
+  session delete SESSION                          Delete a session and disconnect its peers
+  peers SESSION                                   List the peers of a session
+  peer get SESSION PEER                           Show a peer
+  peer delete SESSION PEER                        Delete a peer
+  peer register [-signals FILE] [-node NAME] [-direction in|out] SESSION PEER
+                                                  Register a peer with signals from a JSON or VILLASnode config file
+  events [-session SESSION] [-type TYPE]          Watch events
+  join [-ttl DURATION] SESSION PEER               Mint a join URL
+  turn [-username NAME] [-hint HINT]              Request TURN credentials
+
+The server URL and token default to the SIGNALING_URL and SIGNALING_TOKEN environment variables.
+`
+
+var (
+	client *pkg.APIClient
+	out    *output
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("signalingctl", flag.ExitOnError)
+	url := fs.String("url", getenv("SIGNALING_URL", "http://localhost:8080"), "Base URL of the signaling server")
+	token := fs.String("token", os.Getenv("SIGNALING_TOKEN"), "Bearer token for the API")
+	format := fs.String("o", "table", "Output format (table, json)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *format {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	client = pkg.NewAPIClient(*url, *token)
+	out = &output{
+		json: *format == "json",
+		w:    os.Stdout,
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "sessions":
+		return runSessions(ctx)
+
+	case "session":
+		return runSession(ctx, args)
+
+	case "peers":
+		if len(args) != 1 {
+			return errors.New("usage: peers SESSION")
+		}
+
+		return runPeers(ctx, args[0])
+
+	case "peer":
+		return runPeer(ctx, args)
+
+	case "events":
+		return runEvents(ctx, args)
+
+	case "join":
+		return runJoin(ctx, args)
+
+	case "turn":
+		return runTURN(ctx, args)
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
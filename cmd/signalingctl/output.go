@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+// output renders API objects either as human-readable tables or as JSON.
+type output struct {
+	json bool
+	w    io.Writer
+}
+
+func (o *output) JSON(v any) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// Table writes a header and rows separated by tabs.
+func (o *output) Table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func (o *output) Sessions(sessions []pkg.Session) error {
+	if o.json {
+		return o.JSON(sessions)
+	}
+
+	rows := [][]string{}
+	for _, s := range sessions {
+		connected := 0
+		for _, p := range s.Peers {
+			if !p.Connected.IsZero() {
+				connected++
+			}
+		}
+
+		rows = append(rows, []string{
+			s.Name,
+			formatTime(s.Created),
+			fmt.Sprint(len(s.Peers)),
+			fmt.Sprint(connected),
+			fmt.Sprint(s.Protected),
+		})
+	}
+
+	return o.Table([]string{"NAME", "CREATED", "PEERS", "CONNECTED", "PROTECTED"}, rows)
+}
+
+func (o *output) Session(s *pkg.Session) error {
+	if o.json {
+		return o.JSON(s)
+	}
+
+	fmt.Fprintf(o.w, "Name:      %s\n", s.Name)
+	fmt.Fprintf(o.w, "Created:   %s\n", formatTime(s.Created))
+	fmt.Fprintf(o.w, "Protected: %t\n", s.Protected)
+
+	if len(s.Labels) > 0 {
+		labels := []string{}
+		for k, v := range s.Labels {
+			labels = append(labels, k+"="+v)
+		}
+
+		fmt.Fprintf(o.w, "Labels:    %s\n", strings.Join(labels, ", "))
+	}
+
+	fmt.Fprintln(o.w)
+
+	return o.Peers(s.Peers)
+}
+
+func (o *output) Peers(peers []pkg.Peer) error {
+	if o.json {
+		return o.JSON(peers)
+	}
+
+	rows := [][]string{}
+	for _, p := range peers {
+		rows = append(rows, []string{
+			p.Name,
+			formatID(p.ID),
+			formatTime(p.Created),
+			formatTime(p.Connected),
+			p.Remote,
+			fmt.Sprint(len(p.Signals)),
+		})
+	}
+
+	return o.Table([]string{"NAME", "ID", "CREATED", "CONNECTED", "REMOTE", "SIGNALS"}, rows)
+}
+
+func (o *output) Peer(p *pkg.Peer) error {
+	if o.json {
+		return o.JSON(p)
+	}
+
+	fmt.Fprintf(o.w, "Name:       %s\n", p.Name)
+	fmt.Fprintf(o.w, "ID:         %s\n", formatID(p.ID))
+	fmt.Fprintf(o.w, "Created:    %s\n", formatTime(p.Created))
+	fmt.Fprintf(o.w, "Connected:  %s\n", formatTime(p.Connected))
+
+	if p.Remote != "" {
+		fmt.Fprintf(o.w, "Remote:     %s\n", p.Remote)
+	}
+	if p.UserAgent != "" {
+		fmt.Fprintf(o.w, "User-Agent: %s\n", p.UserAgent)
+	}
+	if len(p.Relays) > 0 {
+		fmt.Fprintf(o.w, "Relays:     %s\n", strings.Join(p.Relays, ", "))
+	}
+
+	if len(p.Signals) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(o.w)
+
+	rows := [][]string{}
+	for i, s := range p.Signals {
+		init := ""
+		if s.Init != nil {
+			init = fmt.Sprint(s.Init)
+		}
+
+		rows = append(rows, []string{fmt.Sprint(i), s.Name, string(s.Type), s.Unit, init})
+	}
+
+	return o.Table([]string{"INDEX", "SIGNAL", "TYPE", "UNIT", "INIT"}, rows)
+}
+
+func (o *output) Event(ev pkg.Event) error {
+	if o.json {
+		return json.NewEncoder(o.w).Encode(ev)
+	}
+
+	peer := ""
+	if ev.Peer != nil {
+		peer = ev.Peer.Name
+	}
+
+	_, err := fmt.Fprintf(o.w, "%s  %-22s  %s  %s\n", ev.Time.Format(time.RFC3339), ev.Type, ev.Session, peer)
+
+	return err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatID(id int32) string {
+	if id == 0 {
+		return "-"
+	}
+
+	return fmt.Sprint(id)
+}
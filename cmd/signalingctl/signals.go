@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+// villasNode is the subset of a VILLASnode node configuration which describes signals.
+type villasNode struct {
+	Type string `json:"type"`
+	In   struct {
+		Signals json.RawMessage `json:"signals"`
+	} `json:"in"`
+	Out struct {
+		Signals json.RawMessage `json:"signals"`
+	} `json:"out"`
+}
+
+type villasSignal struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Unit string `json:"unit"`
+	Init any    `json:"init"`
+}
+
+// loadSignals reads signals from a JSON file which contains either
+// a list of signals, a peer object with a "signals" field
+// or a VILLASnode configuration with a "nodes" object.
+// Only the JSON variant of VILLASnode configurations is supported.
+func loadSignals(path, node, dir string) ([]pkg.Signal, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signals := []pkg.Signal{}
+	if err := json.Unmarshal(buf, &signals); err == nil {
+		return signals, nil
+	}
+
+	doc := struct {
+		Signals []pkg.Signal          `json:"signals"`
+		Nodes   map[string]villasNode `json:"nodes"`
+	}{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if doc.Nodes == nil {
+		return doc.Signals, nil
+	}
+
+	n, err := findNode(doc.Nodes, node)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	switch dir {
+	case "in":
+		raw = n.In.Signals
+	case "out":
+		raw = n.Out.Signals
+	default:
+		return nil, fmt.Errorf("invalid direction: %s", dir)
+	}
+
+	if raw == nil {
+		return nil, fmt.Errorf("node has no %s signals", dir)
+	}
+
+	return parseVillasSignals(raw)
+}
+
+func findNode(nodes map[string]villasNode, name string) (*villasNode, error) {
+	if name != "" {
+		n, ok := nodes[name]
+		if !ok {
+			return nil, fmt.Errorf("node '%s' not found", name)
+		}
+
+		return &n, nil
+	}
+
+	names := []string{}
+	for name, n := range nodes {
+		if n.Type == "webrtc" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return nil, errors.New("no WebRTC node found")
+	case 1:
+		n := nodes[names[0]]
+		return &n, nil
+	default:
+		return nil, fmt.Errorf("multiple WebRTC nodes found (%v), please select one with -node", names)
+	}
+}
+
+// parseVillasSignals parses either a list of signals or
+// the short form {"count": N, "type": "float"} of VILLASnode.
+func parseVillasSignals(raw json.RawMessage) ([]pkg.Signal, error) {
+	vss := []villasSignal{}
+	if err := json.Unmarshal(raw, &vss); err != nil {
+		short := struct {
+			Count int    `json:"count"`
+			Type  string `json:"type"`
+		}{}
+		if err := json.Unmarshal(raw, &short); err != nil {
+			return nil, fmt.Errorf("invalid signals: %w", err)
+		}
+
+		vss = make([]villasSignal, short.Count)
+		for i := range vss {
+			vss[i].Type = short.Type
+		}
+	}
+
+	signals := []pkg.Signal{}
+	for i, vs := range vss {
+		typ, err := signalType(vs.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if vs.Name == "" {
+			vs.Name = fmt.Sprintf("signal%d", i)
+		}
+
+		signals = append(signals, pkg.Signal{
+			Name: vs.Name,
+			Type: typ,
+			Unit: vs.Unit,
+			Init: vs.Init,
+		})
+	}
+
+	return signals, nil
+}
+
+func signalType(s string) (pkg.SignalType, error) {
+	switch s {
+	case "float", "f", "":
+		return pkg.SignalTypeFloat, nil
+	case "integer", "i":
+		return pkg.SignalTypeInteger, nil
+	case "boolean", "b":
+		return pkg.SignalTypeBoolean, nil
+	case "complex", "c":
+		return pkg.SignalTypeComplex, nil
+	default:
+		return "", fmt.Errorf("unknown signal type: %s", s)
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+
+	sf := &secretFlags{}
+	sf.register(fs)
+
+	ttl := durationFlag(7 * 24 * time.Hour)
+	fs.Var(&ttl, "ttl", "Lifetime of the credentials like 12h or 7d")
+
+	expires := fs.String("expires", "", "Absolute expiry time like \"2006-01-02 15:04:05\" in UTC or RFC 3339 (overrides -ttl)")
+	realm := fs.String("realm", "", "Realm of the TURN server (used for coturn output)")
+	format := fs.String("o", "text", "Output format (text, json, env, coturn)")
+
+	uris := []string{}
+	fs.Func("uri", "A TURN URI which is included in the output (can be specified multiple times)", func(s string) error {
+		uris = append(uris, s)
+		return nil
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: generate [options] USERNAME")
+	}
+
+	if err := checkFormat(*format, "text", "json", "env", "coturn"); err != nil {
+		return err
+	}
+
+	ri := pkg.RelayInfo{
+		TTL: time.Duration(ttl),
+	}
+
+	if *expires != "" {
+		exp, err := parseTime(*expires)
+		if err != nil {
+			return err
+		}
+
+		ri.TTL = time.Until(exp)
+	}
+
+	if ri.TTL <= 0 {
+		return errors.New("credentials would already be expired")
+	}
+
+	if err := sf.apply(&ri, true); err != nil {
+		return err
+	}
+
+	user, pass, exp := ri.GetCredentials(fs.Arg(0))
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(&pkg.TURNCredentials{
+			Username: user,
+			Password: pass,
+			TTL:      int(time.Until(exp).Seconds()),
+			URIs:     uris,
+		})
+
+	case "env":
+		fmt.Printf("TURN_USERNAME=%s\n", shellQuote(user))
+		fmt.Printf("TURN_PASSWORD=%s\n", shellQuote(pass))
+		fmt.Printf("TURN_EXPIRES=%d\n", exp.Unix())
+
+		if len(uris) > 0 {
+			fmt.Printf("TURN_URIS=%s\n", shellQuote(strings.Join(uris, ",")))
+		}
+
+	case "coturn":
+		fmt.Println("# TURN REST API configuration for turnserver.conf")
+
+		if ri.Algorithm != pkg.RelayAlgorithmSHA1 {
+			fmt.Printf("# Note: coturn validates TURN REST API credentials with HMAC-SHA1, not %s\n", ri.Algorithm)
+		}
+
+		fmt.Printf("# Credentials for testing: %s / %s (expires %s)\n", user, pass, exp.Format(time.RFC3339))
+		fmt.Println("use-auth-secret")
+
+		for _, secret := range ri.Secrets {
+			fmt.Printf("static-auth-secret=%s\n", secret)
+		}
+
+		if *realm != "" {
+			fmt.Printf("realm=%s\n", *realm)
+		}
+
+	default:
+		fmt.Printf("Username: %s\n", user)
+		fmt.Printf("Password: %s\n", pass)
+		fmt.Printf("Expires:  %s\n", exp.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// shellQuote quotes a value for use in a POSIX shell environment file.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -4,40 +4,186 @@
 package main
 
 import (
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
 )
 
+const usage = `usage: %s <command> [args]
+
+commands:
+  generate [-secret SECRET] [-ttl DURATION] [-o text|json|env|coturn] USERNAME
+      Generate TURN REST API credentials
+  verify [-secret SECRET] USERNAME PASSWORD
+      Verify TURN REST API credentials and report their expiry
+  probe [-secret SECRET] [-username NAME] URI
+      Perform a STUN binding and TURN allocation against a relay
+
+The shared secret defaults to the TURN_SECRET environment variable.
+Run '%[1]s <command> -h' for the options of a command.
+
+For compatibility, '%[1]s SECRET USERNAME [EXPIRES]' is equivalent to 'generate'.
+`
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Printf("usage: %s shared-secret username [expires]\n", os.Args[0])
-		return
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		return errors.New("missing command")
 	}
 
-	secret := os.Args[1]
-	username := os.Args[2]
-	exp := time.Now().Add(7 * 24 * time.Hour) // 1 Week
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "generate":
+		return runGenerate(args)
+
+	case "verify":
+		return runVerify(args)
+
+	case "probe":
+		return runProbe(args)
+
+	case "-h", "-help", "--help", "help":
+		fmt.Fprintf(os.Stdout, usage, os.Args[0])
+		return nil
 
-	if len(os.Args) >= 4 {
-		if ttl, err := ParseDuration(os.Args[3]); err == nil {
-			exp = time.Now().Add(ttl)
-		} else if exp, err = time.Parse("2006-01-02 15:04:05", os.Args[3]); err != nil {
-			panic(err)
+	default:
+		if len(args) >= 1 {
+			return runLegacy(append([]string{cmd}, args...))
 		}
+
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		return fmt.Errorf("unknown command: %s", cmd)
 	}
+}
 
-	user := fmt.Sprintf("%d:%s", exp.Unix(), username)
+// runLegacy implements the original interface: SECRET USERNAME [EXPIRES]
+// where EXPIRES is either a duration or a time like "2006-01-02 15:04:05".
+func runLegacy(args []string) error {
+	genArgs := []string{"-secret", args[0]}
 
-	digest := hmac.New(sha1.New, []byte(secret))
-	digest.Write([]byte(user))
+	if len(args) >= 3 {
+		if _, err := ParseDuration(args[2]); err == nil {
+			genArgs = append(genArgs, "-ttl", args[2])
+		} else {
+			genArgs = append(genArgs, "-expires", args[2])
+		}
+	}
+
+	return runGenerate(append(genArgs, args[1]))
+}
+
+// secretFlags are the options for the shared secret which are common to all commands.
+type secretFlags struct {
+	secrets    []string
+	secretFile string
+	algorithm  string
+}
+
+func (sf *secretFlags) register(fs *flag.FlagSet) {
+	fs.Func("secret", "Shared secret (can be specified multiple times for verify)", func(s string) error {
+		sf.secrets = append(sf.secrets, s)
+		return nil
+	})
+	fs.StringVar(&sf.secretFile, "secret-file", "", "Path of a file containing one shared secret per line")
+	fs.StringVar(&sf.algorithm, "algorithm", "", "HMAC algorithm (sha1, sha256, sha512) (default sha1)")
+}
 
-	passRaw := digest.Sum(nil)
-	pass := base64.StdEncoding.EncodeToString(passRaw)
+// hasSecrets returns true if secrets have been given as options.
+func (sf *secretFlags) hasSecrets() bool {
+	return len(sf.secrets) > 0 || sf.secretFile != ""
+}
+
+// apply configures the secrets and algorithm of a relay.
+// Only options which have been given override the settings of the relay.
+// The TURN_SECRET environment variable is used if the relay has no secrets otherwise.
+// If required is set, an error is returned if no secret is given.
+func (sf *secretFlags) apply(ri *pkg.RelayInfo, required bool) error {
+	switch alg := pkg.RelayAlgorithm(sf.algorithm); alg {
+	case "":
+		if ri.Algorithm == "" {
+			ri.Algorithm = pkg.RelayAlgorithmSHA1
+		}
+	case pkg.RelayAlgorithmSHA1, pkg.RelayAlgorithmSHA256, pkg.RelayAlgorithmSHA512:
+		ri.Algorithm = alg
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", sf.algorithm)
+	}
+
+	secrets := sf.secrets
+	if sf.secretFile != "" {
+		s, err := pkg.ReadSecretFile(sf.secretFile)
+		if err != nil {
+			return err
+		}
+
+		secrets = append(secrets, s...)
+	}
+
+	if len(secrets) == 0 && !ri.HasSecrets() {
+		if s := os.Getenv("TURN_SECRET"); s != "" {
+			secrets = []string{s}
+		}
+	}
+
+	if len(secrets) > 0 {
+		ri.Secrets = secrets
+	} else if required && !ri.HasSecrets() {
+		return errors.New("missing shared secret")
+	}
+
+	return nil
+}
+
+// durationFlag accepts durations with the additional units of ParseDuration like "7d".
+type durationFlag time.Duration
+
+func (d *durationFlag) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *durationFlag) Set(s string) error {
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = durationFlag(v)
+
+	return nil
+}
+
+// parseTime parses an absolute time either in RFC 3339 or "2006-01-02 15:04:05" format.
+// The latter is interpreted as UTC like in the original interface.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02 15:04:05", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+
+	return t, nil
+}
+
+func checkFormat(format string, formats ...string) error {
+	for _, f := range formats {
+		if f == format {
+			return nil
+		}
+	}
 
-	fmt.Printf("Username: %s\n", user)
-	fmt.Printf("Password: %s\n", pass)
+	return fmt.Errorf("unknown output format '%s' (supported: %s)", format, strings.Join(formats, ", "))
 }
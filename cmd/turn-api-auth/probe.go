@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/VILLASframework/signaling/pkg/stun"
+	pionstun "github.com/pion/stun"
+	"github.com/pion/turn/v2"
+)
+
+type probeResult struct {
+	URI            string        `json:"uri"`
+	Username       string        `json:"username,omitempty"`
+	MappedAddress  string        `json:"mapped_address,omitempty"`
+	BindingRTT     time.Duration `json:"-"`
+	RelayedAddress string        `json:"relayed_address,omitempty"`
+	AllocationRTT  time.Duration `json:"-"`
+	Error          string        `json:"error,omitempty"`
+
+	// Round-trip times in milliseconds
+	BindingRTTMs    float64 `json:"binding_rtt_ms,omitempty"`
+	AllocationRTTMs float64 `json:"allocation_rtt_ms,omitempty"`
+}
+
+// runProbe performs a STUN binding request and, for TURN URIs, an allocation
+// using static credentials from the URI or TURN REST API credentials generated from the secret.
+// The URI accepts the same query parameters as the -relay option of the server.
+func runProbe(args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+
+	sf := &secretFlags{}
+	sf.register(fs)
+
+	username := fs.String("username", "probe", "Username for TURN REST API credentials")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of the probe")
+	format := fs.String("o", "text", "Output format (text, json)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: probe [options] URI")
+	}
+
+	if err := checkFormat(*format, "text", "json"); err != nil {
+		return err
+	}
+
+	// Secrets given as options replace those in the URI including a secret file
+	arg := fs.Arg(0)
+	if sf.hasSecrets() {
+		arg = withoutSecrets(arg)
+	}
+
+	ri, err := pkg.NewRelayInfo(arg)
+	if err != nil {
+		return err
+	}
+
+	if err := sf.apply(&ri, false); err != nil {
+		return err
+	}
+
+	uri, _, _, _, err := stun.ParseURI(arg)
+	if err != nil {
+		return err
+	}
+
+	res := &probeResult{
+		URI: ri.URL,
+	}
+
+	user, pass, _ := ri.GetCredentials(*username)
+	res.Username = user
+
+	client, conn, err := newProbeClient(uri, user, pass, ri.Realm, *timeout)
+	if err == nil {
+		done := make(chan error, 1)
+		go func() {
+			done <- probe(client, uri, res)
+		}()
+
+		select {
+		case err = <-done:
+			client.Close()
+		case <-time.After(*timeout):
+			// Closing the client aborts pending transactions
+			client.Close()
+			<-done
+			err = stun.ErrTimeout
+		}
+
+		conn.Close() //nolint:errcheck
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	if *format == "json" {
+		res.BindingRTTMs = float64(res.BindingRTT.Microseconds()) / 1e3
+		res.AllocationRTTMs = float64(res.AllocationRTT.Microseconds()) / 1e3
+
+		if err := json.NewEncoder(os.Stdout).Encode(res); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("URI:             %s\n", res.URI)
+
+		if res.MappedAddress != "" {
+			fmt.Printf("Mapped address:  %s (%s)\n", res.MappedAddress, res.BindingRTT.Round(time.Microsecond))
+		}
+
+		if res.RelayedAddress != "" {
+			fmt.Printf("Relayed address: %s (%s)\n", res.RelayedAddress, res.AllocationRTT.Round(time.Microsecond))
+		}
+	}
+
+	return err
+}
+
+// withoutSecrets removes the secret and secret-file parameters from a relay URI.
+func withoutSecrets(uri string) string {
+	base, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return uri
+	}
+
+	q.Del("secret")
+	q.Del("secret-file")
+
+	if len(q) == 0 {
+		return base
+	}
+
+	return base + "?" + q.Encode()
+}
+
+// newProbeClient creates a TURN client for the transport of the URI.
+// The connection is returned as well as it is not closed by the client.
+func newProbeClient(uri *pionstun.URI, user, pass, realm string, timeout time.Duration) (*turn.Client, net.PacketConn, error) {
+	conn, addr, err := stun.Dial(uri, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &turn.ClientConfig{
+		STUNServerAddr: addr,
+		Conn:           conn,
+		Username:       user,
+		Password:       pass,
+		Realm:          realm,
+		Software:       "villas-signaling turn-api-auth",
+	}
+
+	if uri.Scheme == pionstun.SchemeTypeTURN || uri.Scheme == pionstun.SchemeTypeTURNS {
+		cfg.TURNServerAddr = addr
+	}
+
+	client, err := turn.NewClient(cfg)
+	if err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, nil, err
+	}
+
+	if err := client.Listen(); err != nil {
+		client.Close()
+		conn.Close() //nolint:errcheck
+		return nil, nil, err
+	}
+
+	return client, conn, nil
+}
+
+// probe performs the requests of the probe.
+// The client is closed by the caller to abort pending transactions on timeout.
+func probe(client *turn.Client, uri *pionstun.URI, res *probeResult) error {
+	start := time.Now()
+
+	mapped, err := client.SendBindingRequest()
+	if err != nil {
+		return fmt.Errorf("STUN binding failed: %w", err)
+	}
+
+	res.MappedAddress = mapped.String()
+	res.BindingRTT = time.Since(start)
+
+	if uri.Scheme != pionstun.SchemeTypeTURN && uri.Scheme != pionstun.SchemeTypeTURNS {
+		return nil
+	}
+
+	start = time.Now()
+
+	relayConn, err := client.Allocate()
+	if err != nil {
+		return fmt.Errorf("TURN allocation failed: %w", err)
+	}
+
+	res.RelayedAddress = relayConn.LocalAddr().String()
+	res.AllocationRTT = time.Since(start)
+
+	return relayConn.Close()
+}
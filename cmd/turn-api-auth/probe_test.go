@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/turn/v2"
+)
+
+const (
+	testSecret = "s3cret"
+	testRealm  = "villas.test"
+)
+
+// startTURNServer starts a TURN server on the loopback interface which accepts
+// TURN REST API credentials signed with secret using the HMAC of h.
+// It returns the URI of the server.
+func startTURNServer(t *testing.T, secret string, h func() hash.Hash) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	srv, err := turn.NewServer(turn.ServerConfig{
+		Realm: testRealm,
+		AuthHandler: func(username, realm string, _ net.Addr) ([]byte, bool) {
+			ts, _, ok := strings.Cut(username, ":")
+			if !ok {
+				return nil, false
+			}
+
+			exp, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil || time.Now().Unix() > exp {
+				return nil, false
+			}
+
+			digest := hmac.New(h, []byte(secret))
+			digest.Write([]byte(username))
+			password := base64.StdEncoding.EncodeToString(digest.Sum(nil))
+
+			return turn.GenerateAuthKey(username, realm, password), true
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: conn,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start TURN server: %s", err)
+	}
+
+	t.Cleanup(func() {
+		srv.Close() //nolint:errcheck
+	})
+
+	return fmt.Sprintf("turn:%s?transport=udp", conn.LocalAddr())
+}
+
+func TestProbe(t *testing.T) {
+	uri := startTURNServer(t, testSecret, sha1.New)
+
+	if err := runProbe([]string{"-timeout", "5s", "-secret", testSecret, uri}); err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+
+	if err := runProbe([]string{"-timeout", "5s", "-secret", "wrong", uri}); err == nil {
+		t.Fatal("Probe with wrong secret succeeded")
+	}
+}
+
+// TestProbeTimeout checks that a probe of an unresponsive server times out.
+func TestProbeTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer conn.Close()
+
+	uri := fmt.Sprintf("turn:%s?transport=udp", conn.LocalAddr())
+
+	if err := runProbe([]string{"-timeout", "200ms", "-secret", testSecret, uri}); err == nil || err.Error() != "timed out" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// TestProbeSecretPrecedence checks that secrets given as options replace those in the URI.
+func TestProbeSecretPrecedence(t *testing.T) {
+	uri := startTURNServer(t, testSecret, sha1.New)
+
+	path := filepath.Join(t.TempDir(), "secrets")
+	if err := os.WriteFile(path, []byte("wrong\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret file: %s", err)
+	}
+
+	for _, u := range []string{
+		uri + "&secret=wrong",
+		uri + "&secret-file=" + path,
+	} {
+		if err := runProbe([]string{"-timeout", "5s", "-secret", testSecret, u}); err != nil {
+			t.Errorf("Probe of %s failed: %s", u, err)
+		}
+	}
+}
+
+// TestProbeAlgorithmFromURI checks that the algorithm of the URI is kept
+// if the secret is taken from the environment.
+func TestProbeAlgorithmFromURI(t *testing.T) {
+	uri := startTURNServer(t, testSecret, sha256.New)
+
+	t.Setenv("TURN_SECRET", testSecret)
+
+	if err := runProbe([]string{"-timeout", "5s", uri + "&algorithm=sha256"}); err != nil {
+		t.Fatalf("Probe failed: %s", err)
+	}
+
+	if err := runProbe([]string{"-timeout", "5s", "-algorithm", "sha1", uri + "&algorithm=sha256"}); err == nil {
+		t.Fatal("Probe with overridden algorithm succeeded")
+	}
+}
+
+func TestParseTimeUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+1", 3600)
+	t.Cleanup(func() {
+		time.Local = local
+	})
+
+	got, err := parseTime("2030-01-02 15:04:05")
+	if err != nil {
+		t.Fatalf("Failed to parse time: %s", err)
+	}
+
+	if want := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Unexpected time: got %s, want %s", got, want)
+	}
+}
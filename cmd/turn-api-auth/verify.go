@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+type verifyResult struct {
+	Valid   bool       `json:"valid"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// runVerify checks credentials against all given secrets.
+// It fails if the credentials are invalid or expired.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+
+	sf := &secretFlags{}
+	sf.register(fs)
+
+	format := fs.String("o", "text", "Output format (text, json)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return errors.New("usage: verify [options] USERNAME PASSWORD")
+	}
+
+	if err := checkFormat(*format, "text", "json"); err != nil {
+		return err
+	}
+
+	ri := pkg.RelayInfo{}
+	if err := sf.apply(&ri, true); err != nil {
+		return err
+	}
+
+	exp, err := ri.VerifyCredentials(fs.Arg(0), fs.Arg(1))
+
+	res := verifyResult{
+		Valid: err == nil,
+	}
+
+	if !exp.IsZero() && !errors.Is(err, pkg.ErrCredentialsInvalid) {
+		res.Expires = &exp
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(&res); err != nil {
+			return err
+		}
+	} else if res.Valid {
+		fmt.Printf("Valid, expires %s (in %s)\n", exp.Format(time.RFC3339), time.Until(exp).Round(time.Second))
+	} else if errors.Is(err, pkg.ErrCredentialsExpired) {
+		fmt.Printf("Expired %s (%s ago)\n", exp.Format(time.RFC3339), time.Since(exp).Round(time.Second))
+	} else {
+		fmt.Println("Invalid")
+	}
+
+	return err
+}
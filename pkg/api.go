@@ -6,9 +6,14 @@ package pkg
 import "time"
 
 type Session struct {
-	Name    string    `json:"name"`
-	Created time.Time `json:"created"`
-	Peers   []Peer    `json:"peers"`
+	Name         string            `json:"name"`
+	Created      time.Time         `json:"created"`
+	LastActivity time.Time         `json:"last_activity"`
+	Expires      *time.Time        `json:"expires,omitempty"`
+	Protected    bool              `json:"protected,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Recording    bool              `json:"recording,omitempty"`
+	Peers        []Peer            `json:"peers"`
 }
 
 type SignalType string
@@ -35,4 +40,67 @@ type Peer struct {
 	Created   time.Time `json:"created"`
 	Connected time.Time `json:"connected,omitempty"`
 	Signals   []Signal  `json:"signals,omitempty"`
+	Relays    []string  `json:"relays,omitempty"`
+}
+
+// TURNCredentials is the response of the TURN REST API (draft-uberti-behave-turn-rest).
+type TURNCredentials struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	TTL      int      `json:"ttl"`
+	URIs     []string `json:"uris"`
+}
+
+type JoinURL struct {
+	URL     string    `json:"url"`
+	Session string    `json:"session"`
+	Peer    string    `json:"peer"`
+	Expires time.Time `json:"expires"`
+}
+
+// RelayConfig describes a relay managed via the REST API.
+// Credentials and shared secrets are never included.
+type RelayConfig struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Group     string    `json:"group,omitempty"`
+	Realm     string    `json:"realm,omitempty"`
+	Auth      string    `json:"auth,omitempty"`
+	Algorithm string    `json:"algorithm,omitempty"`
+	TTL       int       `json:"ttl,omitempty"`
+	Disabled  bool      `json:"disabled"`
+	Peers     int       `json:"peers"`
+	Created   time.Time `json:"created"`
+}
+
+// DrainStatus describes the progress of draining the server before a shutdown.
+type DrainStatus struct {
+	Draining bool       `json:"draining"`
+	Started  *time.Time `json:"started,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	URL      string     `json:"url,omitempty"`
+	Sessions int        `json:"sessions"`
+	Peers    int        `json:"peers"`
+}
+
+type HealthStatus string
+
+const (
+	HealthStatusOK       HealthStatus = "ok"
+	HealthStatusDegraded HealthStatus = "degraded"
+	HealthStatusFailed   HealthStatus = "failed"
+)
+
+// HealthCheck is the result of a single check of the liveness or readiness probe.
+type HealthCheck struct {
+	Name    string       `json:"name"`
+	Status  HealthStatus `json:"status"`
+	Message string       `json:"message,omitempty"`
+}
+
+// Health is the response of the liveness and readiness probes.
+// Its status is failed if any of the checks failed.
+type Health struct {
+	Status HealthStatus  `json:"status"`
+	Checks []HealthCheck `json:"checks"`
 }
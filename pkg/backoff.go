@@ -23,7 +23,7 @@ type ExponentialBackoff struct {
 }
 
 func (e *ExponentialBackoff) Next() time.Duration {
-	e.Duration = time.Duration(1.5 * float32(e.Duration)).Round(time.Second)
+	e.Duration = time.Duration(e.Factor * float32(e.Duration)).Round(time.Second)
 	if e.Duration > e.Maximum {
 		e.Duration = e.Maximum
 	}
@@ -0,0 +1,294 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIError is returned by the APIClient for unsuccessful requests.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+// APIClient is a client for the REST API of the signaling server.
+type APIClient struct {
+	// BaseURL of the server like http://localhost:8080
+	BaseURL string
+
+	// Token is sent as bearer token if not empty
+	Token string
+
+	HTTPClient *http.Client
+}
+
+func NewAPIClient(baseURL, token string) *APIClient {
+	return &APIClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (c *APIClient) request(ctx context.Context, method, path string, query url.Values, req any) (*http.Response, error) {
+	u := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if req != nil {
+		buf, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+
+		body = bytes.NewReader(buf)
+	}
+
+	hr, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req != nil {
+		hr.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.Token != "" {
+		hr.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+		}
+
+		errResp := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil {
+			apiErr.Message = errResp.Error
+		}
+
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+func (c *APIClient) do(ctx context.Context, method, path string, query url.Values, req, resp any) error {
+	hr, err := c.request(ctx, method, path, query, req)
+	if err != nil {
+		return err
+	}
+	defer hr.Body.Close()
+
+	if resp == nil || hr.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(hr.Body).Decode(resp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+func (c *APIClient) Sessions(ctx context.Context) ([]Session, error) {
+	resp := struct {
+		Sessions []Session `json:"sessions"`
+	}{}
+
+	if err := c.do(ctx, "GET", "/sessions", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Sessions, nil
+}
+
+func (c *APIClient) Session(ctx context.Context, name string) (*Session, error) {
+	resp := struct {
+		Session *Session `json:"session"`
+	}{}
+
+	if err := c.do(ctx, "GET", "/session/"+url.PathEscape(name), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Session, nil
+}
+
+func (c *APIClient) DeleteSession(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/session/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// ExtendSession renews the lease of a session by ttl or its previous TTL if zero.
+func (c *APIClient) ExtendSession(ctx context.Context, name string, ttl time.Duration) (*Session, error) {
+	q := url.Values{}
+	if ttl > 0 {
+		q.Set("ttl", ttl.String())
+	}
+
+	resp := struct {
+		Session *Session `json:"session"`
+	}{}
+
+	if err := c.do(ctx, "POST", "/session/"+url.PathEscape(name)+"/lease", q, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Session, nil
+}
+
+func (c *APIClient) Peer(ctx context.Context, session, name string) (*Peer, error) {
+	resp := struct {
+		Peer *Peer `json:"peer"`
+	}{}
+
+	if err := c.do(ctx, "GET", "/peer/"+url.PathEscape(session)+"/"+url.PathEscape(name), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Peer, nil
+}
+
+// RegisterPeer creates a peer if it does not exist yet and updates its signals.
+func (c *APIClient) RegisterPeer(ctx context.Context, session, name string, signals []Signal) (*Peer, error) {
+	req := struct {
+		Peer struct {
+			Signals []Signal `json:"signals,omitempty"`
+		} `json:"peer"`
+	}{}
+	req.Peer.Signals = signals
+
+	resp := struct {
+		Peer *Peer `json:"peer"`
+	}{}
+
+	if err := c.do(ctx, "POST", "/peer/"+url.PathEscape(session)+"/"+url.PathEscape(name), nil, &req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Peer, nil
+}
+
+func (c *APIClient) DeletePeer(ctx context.Context, session, name string) error {
+	return c.do(ctx, "DELETE", "/peer/"+url.PathEscape(session)+"/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// JoinURL mints a signed join URL for a peer which is valid for ttl or the server default if zero.
+func (c *APIClient) JoinURL(ctx context.Context, session, peer string, ttl time.Duration) (*JoinURL, error) {
+	q := url.Values{}
+	if ttl > 0 {
+		q.Set("ttl", ttl.String())
+	}
+
+	resp := struct {
+		Join *JoinURL `json:"join"`
+	}{}
+
+	if err := c.do(ctx, "POST", "/join/"+url.PathEscape(session)+"/"+url.PathEscape(peer), q, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Join, nil
+}
+
+// TURNCredentials requests relay credentials from the TURN REST API of the server.
+func (c *APIClient) TURNCredentials(ctx context.Context, username, hint string) (*TURNCredentials, error) {
+	q := url.Values{}
+	if username != "" {
+		q.Set("username", username)
+	}
+	if hint != "" {
+		q.Set("hint", hint)
+	}
+
+	resp := &TURNCredentials{}
+
+	if err := c.do(ctx, "GET", "/turn", q, nil, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Events streams events from the server until the context is cancelled or the connection is lost.
+// If session is not empty, only events of this session are received.
+// If types are given, only events of these types are received.
+func (c *APIClient) Events(ctx context.Context, session string, types []EventType) (<-chan Event, <-chan error, error) {
+	q := url.Values{}
+	if session != "" {
+		q.Set("session", session)
+	}
+	for _, t := range types {
+		q.Add("type", string(t))
+	}
+
+	hr, err := c.request(ctx, "GET", "/events", q, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer hr.Body.Close()
+		defer close(events)
+
+		scanner := bufio.NewScanner(hr.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+
+			ev := Event{}
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				errs <- fmt.Errorf("failed to decode event: %w", err)
+				return
+			}
+
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) {
+			errs <- err
+		}
+	}()
+
+	return events, errs, nil
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package pkg
+
+import "time"
+
+type EventType string
+
+const (
+	EventSessionCreated   EventType = "session.created"
+	EventSessionExpired   EventType = "session.expired"
+	EventSessionDeleted   EventType = "session.deleted"
+	EventPeerRegistered   EventType = "peer.registered"
+	EventPeerConnected    EventType = "peer.connected"
+	EventPeerDisconnected EventType = "peer.disconnected"
+	EventPeerRemoved      EventType = "peer.removed"
+	EventSignalsUpdated   EventType = "peer.signals_updated"
+)
+
+type Event struct {
+	ID      string    `json:"id"`
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+	Peer    *Peer     `json:"peer,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID       string        `json:"id"`
+	Event    Event         `json:"event"`
+	URL      string        `json:"url"`
+	Attempt  int           `json:"attempt"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Status   int           `json:"status,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
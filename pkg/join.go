@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrJoinUnsigned  = errors.New("join URL is not signed")
+	ErrJoinExpired   = errors.New("join URL is expired")
+	ErrJoinSignature = errors.New("invalid join URL signature")
+)
+
+// SignJoin returns the signature for joining a session as a peer until the expiry time.
+func SignJoin(secret, session, peer string, exp time.Time) string {
+	msg := fmt.Sprintf("%d:%s/%s", exp.Unix(), session, peer)
+
+	digest := hmac.New(sha256.New, []byte(secret))
+	digest.Write([]byte(msg))
+
+	return base64.RawURLEncoding.EncodeToString(digest.Sum(nil))
+}
+
+// SignJoinURL adds the expires and signature query parameters to a join URL.
+func SignJoinURL(u *url.URL, secret, session, peer string, exp time.Time) {
+	q := u.Query()
+	q.Set("expires", strconv.FormatInt(exp.Unix(), 10))
+	q.Set("signature", SignJoin(secret, session, peer, exp))
+
+	u.RawQuery = q.Encode()
+}
+
+// VerifyJoin checks the signature and expiry of a join URL's query parameters.
+func VerifyJoin(secret, session, peer string, q url.Values) error {
+	expStr := q.Get("expires")
+	sig := q.Get("signature")
+	if expStr == "" || sig == "" {
+		return ErrJoinUnsigned
+	}
+
+	expUnix, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiry: %w", err)
+	}
+
+	exp := time.Unix(expUnix, 0)
+
+	expected := SignJoin(secret, session, peer, exp)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return ErrJoinSignature
+	}
+
+	if time.Now().After(exp) {
+		return ErrJoinExpired
+	}
+
+	return nil
+}
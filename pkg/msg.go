@@ -5,6 +5,7 @@ package pkg
 
 import (
 	"encoding/json"
+	"time"
 )
 
 type ControlMessage struct {
@@ -27,7 +28,15 @@ type Relay struct {
 	Username string `json:"user"`
 	Password string `json:"pass"`
 	Realm    string `json:"realm"`
-	Expires  string `json:"expires"`
+	Expires  string `json:"expires,omitempty"`
+}
+
+// GoingAwayMessage is sent to all connected peers when the server is draining.
+// Peers should reconnect to the suggested URL or another instance before the deadline.
+type GoingAwayMessage struct {
+	Reason   string    `json:"reason"`
+	URL      string    `json:"url,omitempty"`
+	Deadline time.Time `json:"deadline"`
 }
 
 type SignalingMessage struct {
@@ -36,6 +45,11 @@ type SignalingMessage struct {
 	Candidate   *CandidateMessage   `json:"candidate,omitempty"`
 	Control     *ControlMessage     `json:"control,omitempty"`
 	Description *DescriptionMessage `json:"description,omitempty"`
+	GoingAway   *GoingAwayMessage   `json:"going_away,omitempty"`
+
+	// Trace carries a W3C trace context (traceparent, tracestate)
+	// to correlate the messages of a negotiation across peers.
+	Trace map[string]string `json:"trace,omitempty"`
 }
 
 func (msg SignalingMessage) String() string {
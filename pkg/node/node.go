@@ -0,0 +1,433 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+// Package node simulates a VILLASnode WebRTC node.
+//
+// It follows the signaling protocol of the node-type: the signal list is sent after connecting,
+// the relays provided by the server are used as ICE servers and the control messages decide
+// which of the two peers with the lowest IDs creates the data channel and offers.
+// Samples are exchanged over the data channel in VILLASnode's json format.
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+)
+
+// DataChannelLabel is the label of the data channel created by VILLASnode.
+const DataChannelLabel = "villas"
+
+type Config struct {
+	// URL of the signaling server like ws://localhost:8080
+	URL     string
+	Session string
+	Peer    string
+
+	// Signals are announced to the server and determine the generated sample values
+	Signals []pkg.Signal
+
+	// Rate is the number of samples sent per second (0 disables sending)
+	Rate float64
+
+	// Echo sends received samples back to the remote peer
+	Echo bool
+
+	// Ordered and MaxRetransmits configure the data channel like the node-type settings of the same name
+	Ordered        bool
+	MaxRetransmits *uint16
+
+	// ICEServers are used in addition to the relays provided by the server
+	ICEServers []webrtc.ICEServer
+
+	// OnSample is called for every received sample
+	OnSample func(Sample)
+
+	Logger *slog.Logger
+}
+
+type Stats struct {
+	Connections     int64 `json:"connections"`
+	Negotiations    int64 `json:"negotiations"`
+	SamplesSent     int64 `json:"samples_sent"`
+	SamplesReceived int64 `json:"samples_received"`
+	DecodeErrors    int64 `json:"decode_errors"`
+}
+
+type Node struct {
+	Config
+
+	logger *slog.Logger
+
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+
+	// events are closures which are executed by the Run loop
+	events chan func()
+	done   chan struct{}
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	// Owned by the Run loop
+	iceServers []webrtc.ICEServer
+	peerID     int32
+	pc         *peerConnection
+	sequence   uint64
+
+	connections     atomic.Int64
+	negotiations    atomic.Int64
+	samplesSent     atomic.Int64
+	samplesReceived atomic.Int64
+	decodeErrors    atomic.Int64
+}
+
+func New(cfg Config) *Node {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Node{
+		Config: cfg,
+		logger: logger.With(slog.String("session", cfg.Session), slog.String("peer", cfg.Peer)),
+		events: make(chan func(), 256),
+		done:   make(chan struct{}),
+		ready:  make(chan struct{}),
+	}
+}
+
+// Ready is closed once the data channel has been opened for the first time.
+func (n *Node) Ready() <-chan struct{} {
+	return n.ready
+}
+
+func (n *Node) Stats() Stats {
+	return Stats{
+		Connections:     n.connections.Load(),
+		Negotiations:    n.negotiations.Load(),
+		SamplesSent:     n.samplesSent.Load(),
+		SamplesReceived: n.samplesReceived.Load(),
+		DecodeErrors:    n.decodeErrors.Load(),
+	}
+}
+
+// Run connects to the signaling server and exchanges samples until the context is cancelled
+// or the connection to the server is lost.
+func (n *Node) Run(ctx context.Context) error {
+	defer close(n.done)
+
+	if err := n.connect(ctx); err != nil {
+		return err
+	}
+	defer n.conn.Close()
+
+	msgs := make(chan *pkg.SignalingMessage)
+	errs := make(chan error, 1)
+
+	go n.readLoop(msgs, errs)
+
+	var tick <-chan time.Time
+	if n.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / n.Rate))
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	defer n.closePeerConnection()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.writeMutex.Lock()
+			err := n.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			n.writeMutex.Unlock()
+
+			if err == nil {
+				select {
+				case <-errs:
+				case <-time.After(time.Second):
+				}
+			}
+
+			return nil
+
+		case err := <-errs:
+			return fmt.Errorf("lost connection to signaling server: %w", err)
+
+		case msg := <-msgs:
+			n.handleMessage(msg)
+
+		case f := <-n.events:
+			f()
+
+		case <-tick:
+			n.sendSample()
+		}
+	}
+}
+
+// connect opens the WebSocket connection and performs the handshake
+// which consists of the signals sent by the node and the relays sent by the server.
+func (n *Node) connect(ctx context.Context) error {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	u = u.JoinPath(url.PathEscape(n.Session), url.PathEscape(n.Peer))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to signaling server: %w", err)
+	}
+
+	n.conn = conn
+
+	if err := n.send(&pkg.SignalingMessage{
+		Signals: n.Signals,
+	}); err != nil {
+		conn.Close() //nolint:errcheck
+		return fmt.Errorf("failed to send signals: %w", err)
+	}
+
+	msg := &pkg.SignalingMessage{}
+	if err := conn.ReadJSON(msg); err != nil {
+		conn.Close() //nolint:errcheck
+		return fmt.Errorf("failed to receive relays: %w", err)
+	}
+
+	if msg.Control != nil || msg.Description != nil || msg.Candidate != nil {
+		conn.Close() //nolint:errcheck
+		return errors.New("expected relays message")
+	}
+
+	n.setRelays(msg.Relays)
+
+	n.logger.Info("Connected to signaling server", slog.Int("relays", len(msg.Relays)))
+
+	return nil
+}
+
+func (n *Node) readLoop(msgs chan<- *pkg.SignalingMessage, errs chan<- error) {
+	for {
+		msg := &pkg.SignalingMessage{}
+		if err := n.conn.ReadJSON(msg); err != nil {
+			errs <- err
+			return
+		}
+
+		select {
+		case msgs <- msg:
+		case <-n.done:
+			return
+		}
+	}
+}
+
+func (n *Node) send(msg *pkg.SignalingMessage) error {
+	n.writeMutex.Lock()
+	defer n.writeMutex.Unlock()
+
+	return n.conn.WriteJSON(msg)
+}
+
+// post schedules f for execution by the Run loop unless the peer connection has been replaced in the meantime.
+// It is used by the callbacks of pion which are invoked from its own goroutines.
+func (n *Node) post(pc *peerConnection, f func()) {
+	select {
+	case n.events <- func() {
+		if n.pc == pc {
+			f()
+		}
+	}:
+	case <-n.done:
+	}
+}
+
+func (n *Node) handleMessage(msg *pkg.SignalingMessage) {
+	n.logger.Debug("Received signaling message", slog.String("message", msg.String()))
+
+	switch {
+	case msg.Control != nil:
+		n.handleControl(msg.Control)
+
+	case msg.Description != nil:
+		if n.pc == nil {
+			n.logger.Debug("Ignoring description while in standby")
+			return
+		}
+
+		n.pc.handleDescription(msg.Description)
+
+	case msg.Candidate != nil:
+		if n.pc == nil {
+			return
+		}
+
+		n.pc.handleCandidate(msg.Candidate)
+
+	case msg.Relays != nil:
+		// Refreshed credentials are used for the next peer connection
+		n.setRelays(msg.Relays)
+
+	case msg.GoingAway != nil:
+		n.logger.Warn("Signaling server is going away",
+			slog.String("reason", msg.GoingAway.Reason),
+			slog.String("url", msg.GoingAway.URL),
+			slog.Time("deadline", msg.GoingAway.Deadline))
+	}
+}
+
+func (n *Node) setRelays(relays []pkg.Relay) {
+	n.iceServers = []webrtc.ICEServer{}
+
+	for _, r := range relays {
+		n.iceServers = append(n.iceServers, webrtc.ICEServer{
+			URLs:       []string{r.URL},
+			Username:   r.Username,
+			Credential: r.Password,
+		})
+	}
+
+	n.iceServers = append(n.iceServers, n.ICEServers...)
+}
+
+// handleControl selects the two connected peers with the lowest IDs for the connection.
+// The first of them creates the data channel and starts the negotiation.
+// All other peers wait in standby.
+func (n *Node) handleControl(c *pkg.ControlMessage) {
+	n.peerID = c.PeerID
+
+	fst, snd := int32(-1), int32(-1)
+	for _, p := range c.Peers {
+		if p.Connected.IsZero() {
+			continue
+		}
+
+		if fst < 0 || p.ID < fst {
+			fst, snd = p.ID, fst
+		} else if snd < 0 || p.ID < snd {
+			snd = p.ID
+		}
+	}
+
+	if snd < 0 {
+		if n.pc != nil {
+			n.logger.Info("Remote peer left, waiting in standby")
+			n.closePeerConnection()
+		} else {
+			n.logger.Info("Waiting for remote peer")
+		}
+
+		return
+	}
+
+	if n.peerID != fst && n.peerID != snd {
+		n.logger.Warn("There are already two peers connected to this session, waiting in standby")
+		n.closePeerConnection()
+		return
+	}
+
+	first := n.peerID == fst
+
+	remote := fst
+	if first {
+		remote = snd
+	}
+
+	if n.pc != nil && n.pc.remote == remote {
+		return
+	}
+
+	n.closePeerConnection()
+
+	pc, err := n.newPeerConnection(first, remote)
+	if err != nil {
+		n.logger.Error("Failed to create peer connection", slog.Any("error", err))
+		return
+	}
+
+	n.pc = pc
+}
+
+func (n *Node) closePeerConnection() {
+	if n.pc == nil {
+		return
+	}
+
+	pc := n.pc
+	n.pc = nil
+
+	// Closing waits for callbacks which might be blocked in post
+	go pc.close()
+}
+
+func (n *Node) sendSample() {
+	if n.pc == nil || !n.pc.open {
+		return
+	}
+
+	n.sequence++
+	smp := generateSample(n.Signals, n.sequence, time.Now())
+
+	if err := n.pc.sendSamples(smp); err != nil {
+		n.logger.Warn("Failed to send sample", slog.Any("error", err))
+		return
+	}
+
+	n.samplesSent.Add(1)
+}
+
+func (n *Node) receive(buf []byte, received time.Time) {
+	smps, err := decodeSamples(buf)
+	if err != nil {
+		n.decodeErrors.Add(1)
+		n.logger.Warn("Failed to decode samples", slog.Any("error", err))
+		return
+	}
+
+	n.samplesReceived.Add(int64(len(smps)))
+
+	for _, smp := range smps {
+		smp.Received = received
+
+		if n.OnSample != nil {
+			n.OnSample(smp)
+		}
+	}
+
+	if n.Echo {
+		if err := n.pc.sendSamples(smps...); err != nil {
+			n.logger.Warn("Failed to echo samples", slog.Any("error", err))
+		} else {
+			n.samplesSent.Add(int64(len(smps)))
+		}
+	}
+}
+
+func (n *Node) setReady() {
+	n.connections.Add(1)
+	n.readyOnce.Do(func() {
+		close(n.ready)
+	})
+}
+
+func encodeSamples(smps []Sample) ([]byte, error) {
+	if len(smps) == 1 {
+		return json.Marshal(&smps[0])
+	}
+
+	return json.Marshal(smps)
+}
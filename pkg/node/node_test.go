@@ -0,0 +1,289 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/gorilla/websocket"
+)
+
+// TestHandleControl checks which peers are elected for the connection.
+func TestHandleControl(t *testing.T) {
+	connected := time.Now()
+
+	peers := func(ids ...int32) []pkg.Peer {
+		ps := []pkg.Peer{}
+		for _, id := range ids {
+			p := pkg.Peer{ID: id}
+
+			// Negative IDs denote peers which are known but not connected
+			if id < 0 {
+				p.ID = -id
+			} else {
+				p.Connected = connected
+			}
+
+			ps = append(ps, p)
+		}
+
+		return ps
+	}
+
+	for _, tc := range []struct {
+		name   string
+		self   int32
+		peers  []pkg.Peer
+		remote int32 // 0 for standby
+		polite bool
+	}{
+		{"alone", 1, peers(1), 0, false},
+		{"first", 1, peers(1, 2), 2, false},
+		{"second", 2, peers(1, 2), 1, true},
+		{"unordered", 5, peers(7, 5), 7, false},
+		{"third", 3, peers(1, 2, 3), 0, false},
+		{"lowest IDs", 2, peers(4, 3, 2), 3, false},
+		{"disconnected", 3, peers(-1, 2, 3), 2, true},
+		{"only disconnected", 1, peers(1, -2), 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n := New(Config{
+				Logger: slog.New(slog.NewTextHandler(&strings.Builder{}, nil)),
+			})
+			defer n.closePeerConnection()
+
+			n.handleControl(&pkg.ControlMessage{
+				PeerID: tc.self,
+				Peers:  tc.peers,
+			})
+
+			if n.peerID != tc.self {
+				t.Errorf("Unexpected peer ID: %d", n.peerID)
+			}
+
+			if tc.remote == 0 {
+				if n.pc != nil {
+					t.Fatalf("Peer is not in standby but connects to %d", n.pc.remote)
+				}
+
+				return
+			} else if n.pc == nil {
+				t.Fatal("Peer is in standby")
+			}
+
+			if n.pc.remote != tc.remote || n.pc.polite != tc.polite {
+				t.Fatalf("Unexpected peer connection: remote %d, polite %v", n.pc.remote, n.pc.polite)
+			}
+
+			// The connection is kept as long as the remote peer stays the same
+			pc := n.pc
+			n.handleControl(&pkg.ControlMessage{
+				PeerID: tc.self,
+				Peers:  append(tc.peers, peers(100)...),
+			})
+
+			if n.pc != pc {
+				t.Error("Peer connection has been replaced although the remote peer did not change")
+			}
+
+			// The connection is closed once the remote peer has left
+			n.handleControl(&pkg.ControlMessage{
+				PeerID: tc.self,
+				Peers:  peers(tc.self),
+			})
+
+			if n.pc != nil {
+				t.Error("Peer connection has not been closed after the remote peer left")
+			}
+		})
+	}
+}
+
+// testServer is a minimal signaling server which forwards all messages to the other peers
+// of a single session and sends control messages whenever a peer connects or disconnects.
+type testServer struct {
+	upgrader websocket.Upgrader
+
+	mutex  sync.Mutex
+	lastID int32
+	peers  map[int32]*testPeer
+}
+
+type testPeer struct {
+	pkg.Peer
+
+	conn  *websocket.Conn
+	mutex sync.Mutex
+}
+
+func (p *testPeer) send(msg *pkg.SignalingMessage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.conn.WriteJSON(msg) //nolint:errcheck
+}
+
+// broadcast sends a control message to all peers.
+// It must be called with the mutex held.
+func (s *testServer) broadcast() {
+	peers := []pkg.Peer{}
+	for _, p := range s.peers {
+		peers = append(peers, p.Peer)
+	}
+
+	for _, p := range s.peers {
+		p.send(&pkg.SignalingMessage{
+			Control: &pkg.ControlMessage{
+				PeerID: p.ID,
+				Peers:  peers,
+			},
+		})
+	}
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Handshake
+	signals := &pkg.SignalingMessage{}
+	if err := conn.ReadJSON(signals); err != nil {
+		return
+	}
+
+	if err := conn.WriteJSON(&pkg.SignalingMessage{Relays: []pkg.Relay{}}); err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.lastID++
+	p := &testPeer{
+		Peer: pkg.Peer{
+			Name:      r.PathValue("peer"),
+			ID:        s.lastID,
+			Connected: time.Now(),
+			Signals:   signals.Signals,
+		},
+		conn: conn,
+	}
+	s.peers[p.ID] = p
+	s.broadcast()
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.peers, p.ID)
+		s.broadcast()
+		s.mutex.Unlock()
+	}()
+
+	for {
+		msg := &pkg.SignalingMessage{}
+		if err := conn.ReadJSON(msg); err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		for _, o := range s.peers {
+			if o != p {
+				o.send(msg)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// TestNodes checks that two nodes connect to each other via a signaling server and exchange samples.
+func TestNodes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/{session}/{peer}", &testServer{
+		peers: map[int32]*testPeer{},
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
+
+	nodes := []*Node{}
+	received := []*atomic.Int64{}
+	errs := make(chan error, 2)
+
+	for _, name := range []string{"a", "b"} {
+		cnt := &atomic.Int64{}
+
+		n := New(Config{
+			URL:     "ws" + strings.TrimPrefix(srv.URL, "http"),
+			Session: "test",
+			Peer:    name,
+			Signals: []pkg.Signal{{Name: "voltage", Type: pkg.SignalTypeFloat}},
+			Rate:    50,
+			Ordered: true,
+			OnSample: func(Sample) {
+				cnt.Add(1)
+			},
+			Logger: logger,
+		})
+
+		go func() {
+			errs <- n.Run(ctx)
+		}()
+
+		nodes = append(nodes, n)
+		received = append(received, cnt)
+	}
+
+	timeout := time.After(30 * time.Second)
+
+	for _, n := range nodes {
+		select {
+		case <-n.Ready():
+		case err := <-errs:
+			t.Fatalf("Node stopped: %v", err)
+		case <-timeout:
+			t.Fatal("Timed out waiting for data channel")
+		}
+	}
+
+	for i := range nodes {
+		for received[i].Load() < 5 {
+			select {
+			case err := <-errs:
+				t.Fatalf("Node stopped: %v", err)
+			case <-timeout:
+				t.Fatalf("Timed out waiting for samples of node %d", i)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	cancel()
+
+	for range nodes {
+		if err := <-errs; err != nil {
+			t.Errorf("Node failed: %s", err)
+		}
+	}
+
+	for i, n := range nodes {
+		if stats := n.Stats(); stats.Connections != 1 || stats.SamplesSent == 0 || stats.DecodeErrors != 0 {
+			t.Errorf("Unexpected stats of node %d: %+v", i, stats)
+		}
+	}
+}
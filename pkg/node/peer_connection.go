@@ -0,0 +1,274 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+	"github.com/pion/webrtc/v3"
+)
+
+// peerConnection implements the perfect negotiation pattern for the connection to a single remote peer.
+// The first peer is impolite and ignores colliding offers while the second one rolls back its own offer.
+// All methods are called from the Run loop of the node.
+type peerConnection struct {
+	node   *Node
+	logger *slog.Logger
+
+	pc *webrtc.PeerConnection
+	dc *webrtc.DataChannel
+
+	remote      int32
+	polite      bool
+	ignoreOffer bool
+	open        bool
+
+	// pending candidates which arrived before the remote description
+	pending []webrtc.ICECandidateInit
+}
+
+func (n *Node) newPeerConnection(first bool, remote int32) (*peerConnection, error) {
+	wpc, err := webrtc.NewPeerConnection(webrtc.Configuration{
+		ICEServers: n.iceServers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &peerConnection{
+		node:   n,
+		logger: n.logger.With(slog.Int("remote", int(remote))),
+		pc:     wpc,
+		remote: remote,
+		polite: !first,
+	}
+
+	wpc.OnNegotiationNeeded(func() {
+		n.post(p, p.negotiate)
+	})
+
+	wpc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+
+		init := c.ToJSON()
+
+		n.post(p, func() {
+			msg := &pkg.CandidateMessage{
+				Spd: init.Candidate,
+			}
+
+			if init.SDPMid != nil {
+				msg.Mid = *init.SDPMid
+			}
+
+			p.send(&pkg.SignalingMessage{
+				Candidate: msg,
+			})
+		})
+	})
+
+	wpc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		n.post(p, func() {
+			p.logger.Info("Connection state changed", slog.String("state", s.String()))
+
+			// Start over with a fresh connection to the same remote peer
+			if s == webrtc.PeerConnectionStateFailed {
+				n.closePeerConnection()
+
+				if pc, err := n.newPeerConnection(first, remote); err != nil {
+					p.logger.Error("Failed to create peer connection", slog.Any("error", err))
+				} else {
+					n.pc = pc
+				}
+			}
+		})
+	})
+
+	wpc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		n.post(p, func() {
+			p.setupDataChannel(dc)
+		})
+	})
+
+	if first {
+		maxRetransmits := n.MaxRetransmits
+		ordered := n.Ordered
+
+		dc, err := wpc.CreateDataChannel(DataChannelLabel, &webrtc.DataChannelInit{
+			Ordered:        &ordered,
+			MaxRetransmits: maxRetransmits,
+		})
+		if err != nil {
+			wpc.Close() //nolint:errcheck
+			return nil, err
+		}
+
+		p.setupDataChannel(dc)
+	}
+
+	p.logger.Info("Created peer connection", slog.Bool("polite", p.polite))
+
+	return p, nil
+}
+
+func (p *peerConnection) close() {
+	if err := p.pc.Close(); err != nil {
+		p.logger.Warn("Failed to close peer connection", slog.Any("error", err))
+	}
+}
+
+func (p *peerConnection) send(msg *pkg.SignalingMessage) {
+	if err := p.node.send(msg); err != nil {
+		p.logger.Error("Failed to send signaling message", slog.Any("error", err))
+	}
+}
+
+func (p *peerConnection) sendDescription() {
+	desc := p.pc.LocalDescription()
+
+	p.send(&pkg.SignalingMessage{
+		Description: &pkg.DescriptionMessage{
+			Type: desc.Type.String(),
+			Spd:  desc.SDP,
+		},
+	})
+}
+
+func (p *peerConnection) negotiate() {
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		p.logger.Error("Failed to create offer", slog.Any("error", err))
+		return
+	}
+
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		p.logger.Error("Failed to set local description", slog.Any("error", err))
+		return
+	}
+
+	p.sendDescription()
+}
+
+func (p *peerConnection) handleDescription(d *pkg.DescriptionMessage) {
+	typ := webrtc.NewSDPType(d.Type)
+
+	collision := typ == webrtc.SDPTypeOffer && p.pc.SignalingState() != webrtc.SignalingStateStable
+
+	p.ignoreOffer = !p.polite && collision
+	if p.ignoreOffer {
+		p.logger.Info("Ignoring colliding offer")
+		return
+	}
+
+	if collision {
+		if err := p.pc.SetLocalDescription(webrtc.SessionDescription{
+			Type: webrtc.SDPTypeRollback,
+		}); err != nil {
+			p.logger.Error("Failed to roll back local offer", slog.Any("error", err))
+			return
+		}
+	}
+
+	if err := p.pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: typ,
+		SDP:  d.Spd,
+	}); err != nil {
+		p.logger.Error("Failed to set remote description", slog.Any("error", err))
+		return
+	}
+
+	for _, c := range p.pending {
+		if err := p.pc.AddICECandidate(c); err != nil {
+			p.logger.Warn("Failed to add candidate", slog.Any("error", err))
+		}
+	}
+
+	p.pending = nil
+
+	if typ == webrtc.SDPTypeOffer {
+		answer, err := p.pc.CreateAnswer(nil)
+		if err != nil {
+			p.logger.Error("Failed to create answer", slog.Any("error", err))
+			return
+		}
+
+		if err := p.pc.SetLocalDescription(answer); err != nil {
+			p.logger.Error("Failed to set local description", slog.Any("error", err))
+			return
+		}
+
+		p.sendDescription()
+	}
+
+	p.node.negotiations.Add(1)
+}
+
+func (p *peerConnection) handleCandidate(c *pkg.CandidateMessage) {
+	mid := c.Mid
+	init := webrtc.ICECandidateInit{
+		Candidate: c.Spd,
+		SDPMid:    &mid,
+	}
+
+	if p.pc.RemoteDescription() == nil {
+		p.pending = append(p.pending, init)
+		return
+	}
+
+	if err := p.pc.AddICECandidate(init); err != nil && !p.ignoreOffer {
+		p.logger.Warn("Failed to add candidate", slog.Any("error", err))
+	}
+}
+
+func (p *peerConnection) setupDataChannel(dc *webrtc.DataChannel) {
+	if dc.Label() != DataChannelLabel {
+		p.logger.Warn("Ignoring unknown data channel", slog.String("label", dc.Label()))
+		return
+	}
+
+	p.dc = dc
+
+	dc.OnOpen(func() {
+		p.node.post(p, func() {
+			p.logger.Info("Data channel opened")
+
+			p.open = true
+			p.node.setReady()
+		})
+	})
+
+	dc.OnClose(func() {
+		p.node.post(p, func() {
+			p.logger.Info("Data channel closed")
+
+			p.open = false
+		})
+	})
+
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		received := time.Now()
+
+		p.node.post(p, func() {
+			p.node.receive(msg.Data, received)
+		})
+	})
+}
+
+func (p *peerConnection) sendSamples(smps ...Sample) error {
+	if p.dc == nil || !p.open {
+		return errors.New("data channel is not open")
+	}
+
+	buf, err := encodeSamples(smps)
+	if err != nil {
+		return err
+	}
+
+	return p.dc.SendText(string(buf))
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+// Sample is a set of signal values as exchanged by VILLASnode.
+type Sample struct {
+	Sequence uint64
+	Origin   time.Time
+	Received time.Time
+	Data     []any
+}
+
+// Complex is the JSON representation of a complex signal value.
+type Complex struct {
+	Real float64 `json:"real"`
+	Imag float64 `json:"imag"`
+}
+
+// jsonSample is the wire format of VILLASnode's json format.
+type jsonSample struct {
+	TS struct {
+		Origin [2]int64 `json:"origin"`
+	} `json:"ts"`
+	Sequence uint64 `json:"sequence"`
+	Data     []any  `json:"data"`
+}
+
+func (s *Sample) MarshalJSON() ([]byte, error) {
+	js := jsonSample{
+		Sequence: s.Sequence,
+		Data:     s.Data,
+	}
+
+	js.TS.Origin = [2]int64{s.Origin.Unix(), int64(s.Origin.Nanosecond())}
+
+	return json.Marshal(&js)
+}
+
+func (s *Sample) UnmarshalJSON(b []byte) error {
+	js := jsonSample{}
+	if err := json.Unmarshal(b, &js); err != nil {
+		return err
+	}
+
+	s.Sequence = js.Sequence
+	s.Origin = time.Unix(js.TS.Origin[0], js.TS.Origin[1])
+	s.Data = js.Data
+
+	return nil
+}
+
+// decodeSamples parses a data channel message which contains either a single sample or a list of samples.
+func decodeSamples(b []byte) ([]Sample, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty message")
+	}
+
+	if b[0] == '[' {
+		smps := []Sample{}
+		if err := json.Unmarshal(b, &smps); err != nil {
+			return nil, err
+		}
+
+		return smps, nil
+	}
+
+	smp := Sample{}
+	if err := json.Unmarshal(b, &smp); err != nil {
+		return nil, err
+	}
+
+	return []Sample{smp}, nil
+}
+
+// generateSample returns a sample with synthetic values for the given signals.
+// Floats follow a sine wave with an amplitude increasing with the signal index,
+// integers count the samples and booleans toggle with each sample.
+func generateSample(signals []pkg.Signal, seq uint64, ts time.Time) Sample {
+	phase := 2 * math.Pi * float64(ts.UnixNano()%int64(time.Second)) / float64(time.Second)
+
+	data := make([]any, len(signals))
+	for i, sig := range signals {
+		amplitude := float64(i + 1)
+
+		switch sig.Type {
+		case pkg.SignalTypeInteger:
+			data[i] = int64(seq)
+		case pkg.SignalTypeBoolean:
+			data[i] = seq%2 == 0
+		case pkg.SignalTypeComplex:
+			data[i] = Complex{
+				Real: amplitude * math.Cos(phase),
+				Imag: amplitude * math.Sin(phase),
+			}
+		default:
+			data[i] = amplitude * math.Sin(phase)
+		}
+	}
+
+	return Sample{
+		Sequence: seq,
+		Origin:   ts,
+		Data:     data,
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VILLASframework/signaling/pkg"
+)
+
+func TestEncodeDecodeSamples(t *testing.T) {
+	signals := []pkg.Signal{
+		{Name: "voltage", Type: pkg.SignalTypeFloat},
+		{Name: "count", Type: pkg.SignalTypeInteger},
+		{Name: "breaker", Type: pkg.SignalTypeBoolean},
+		{Name: "phasor", Type: pkg.SignalTypeComplex},
+	}
+
+	ts := time.Unix(1700000000, 123456789)
+
+	for _, n := range []int{1, 3} {
+		smps := []Sample{}
+		for i := 0; i < n; i++ {
+			smps = append(smps, generateSample(signals, uint64(i+1), ts.Add(time.Duration(i)*time.Millisecond)))
+		}
+
+		buf, err := encodeSamples(smps)
+		if err != nil {
+			t.Fatalf("Failed to encode samples: %s", err)
+		}
+
+		// A single sample is sent as an object, multiple ones as a list
+		if isList := strings.HasPrefix(string(buf), "["); isList != (n > 1) {
+			t.Errorf("Unexpected encoding of %d samples: %s", n, buf)
+		}
+
+		got, err := decodeSamples(buf)
+		if err != nil {
+			t.Fatalf("Failed to decode samples: %s", err)
+		}
+
+		if len(got) != n {
+			t.Fatalf("Unexpected number of samples: got %d, want %d", len(got), n)
+		}
+
+		for i, smp := range got {
+			if smp.Sequence != smps[i].Sequence || !smp.Origin.Equal(smps[i].Origin) {
+				t.Errorf("Unexpected sample %d: %+v", i, smp)
+			}
+
+			// Numbers are decoded as floats and complex values as objects
+			want := []any{
+				smps[i].Data[0],
+				float64(smps[i].Sequence),
+				smps[i].Sequence%2 == 0,
+				map[string]any{
+					"real": smps[i].Data[3].(Complex).Real,
+					"imag": smps[i].Data[3].(Complex).Imag,
+				},
+			}
+
+			if !reflect.DeepEqual(smp.Data, want) {
+				t.Errorf("Unexpected data of sample %d: got %v, want %v", i, smp.Data, want)
+			}
+		}
+	}
+}
+
+func TestDecodeSamplesVILLASnode(t *testing.T) {
+	for _, tc := range []struct {
+		msg string
+		n   int
+		ok  bool
+	}{
+		{`{"ts":{"origin":[1700000000,500]},"sequence":7,"data":[1.5,true]}`, 1, true},
+		{`[{"ts":{"origin":[1700000000,0]},"sequence":1,"data":[]},{"ts":{"origin":[1700000000,1]},"sequence":2,"data":[]}]`, 2, true},
+		{`[]`, 0, true},
+		{``, 0, false},
+		{`{"sequence":"one"}`, 0, false},
+		{`[{"data":1}]`, 0, false},
+		{`not json`, 0, false},
+	} {
+		smps, err := decodeSamples([]byte(tc.msg))
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("Invalid message %q has been decoded", tc.msg)
+			}
+
+			continue
+		} else if err != nil {
+			t.Errorf("Failed to decode %q: %s", tc.msg, err)
+			continue
+		}
+
+		if len(smps) != tc.n {
+			t.Errorf("Unexpected number of samples in %q: %d", tc.msg, len(smps))
+		}
+	}
+
+	smps, _ := decodeSamples([]byte(`{"ts":{"origin":[1700000000,500]},"sequence":7,"data":[1.5]}`))
+	if smp := smps[0]; smp.Sequence != 7 || !smp.Origin.Equal(time.Unix(1700000000, 500)) {
+		t.Errorf("Unexpected sample: %+v", smp)
+	}
+}
@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package pkg
+
+import "time"
+
+// Record is a line of a session recording in the JSON Lines format.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+
+	// Sender is empty for messages originating from the server
+	Sender     string   `json:"sender,omitempty"`
+	Recipients []string `json:"recipients"`
+
+	Message SignalingMessage `json:"message"`
+}
@@ -6,8 +6,18 @@ package pkg
 import (
 	"crypto/hmac"
 	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"hash"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/VILLASframework/signaling/pkg/stun"
@@ -17,17 +27,148 @@ const (
 	DefaultRelayTTL = 1 * time.Hour
 )
 
+var (
+	ErrCredentialsInvalid = errors.New("invalid credentials")
+	ErrCredentialsExpired = errors.New("credentials are expired")
+)
+
+type RelayAlgorithm string
+
+const (
+	RelayAlgorithmSHA1   RelayAlgorithm = "sha1"
+	RelayAlgorithmSHA256 RelayAlgorithm = "sha256"
+	RelayAlgorithmSHA512 RelayAlgorithm = "sha512"
+)
+
+func (a RelayAlgorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case RelayAlgorithmSHA1, "":
+		return sha1.New, nil
+	case RelayAlgorithmSHA256:
+		return sha256.New, nil
+	case RelayAlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", a)
+	}
+}
+
 type RelayInfo struct {
 	URL   string
 	Realm string
 
+	// Group is used to select relays for peers
+	Group string
+
 	Username string
 	Password string
 
-	TTL    time.Duration
+	TTL       time.Duration
+	Algorithm RelayAlgorithm
+
+	// Shared secrets for TURN REST API credentials.
+	// The first secret is used for issuing credentials,
+	// all secrets are accepted for verifying them to allow for a seamless rotation.
+	Secrets    []string
+	SecretFile string
+
+	// Deprecated: Secret is accepted in addition to Secrets. Use Secrets instead.
 	Secret string
+
+	secretFile *secretFile
 }
 
+// secretFile caches the secrets read from a file with one secret per line.
+// The file is re-read if it has been modified.
+type secretFile struct {
+	path    string
+	secrets []string
+	modTime time.Time
+	failed  bool
+	mutex   sync.Mutex
+}
+
+// Secrets returns the secrets of the file.
+// If the file can not be read, the secrets which have been read last are returned along with the error.
+// The failure is logged once until the file can be read again.
+func (f *secretFile) Secrets() ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	secrets, err := f.read()
+	if err != nil {
+		if !f.failed {
+			slog.Warn("Failed to read secret file. Using previous secrets",
+				slog.String("path", f.path),
+				slog.Int("secrets", len(f.secrets)),
+				slog.Any("error", err))
+		}
+
+		f.failed = true
+
+		return f.secrets, err
+	}
+
+	if f.failed {
+		slog.Info("Secret file is readable again", slog.String("path", f.path))
+	}
+
+	f.failed = false
+
+	return secrets, nil
+}
+
+func (f *secretFile) read() ([]string, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat secret file: %w", err)
+	}
+
+	if fi.ModTime().Equal(f.modTime) {
+		return f.secrets, nil
+	}
+
+	secrets, err := ReadSecretFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.secrets = secrets
+	f.modTime = fi.ModTime()
+
+	return f.secrets, nil
+}
+
+// ReadSecretFile reads shared secrets from a file with one secret per line.
+// Empty lines and lines starting with # are ignored.
+func ReadSecretFile(path string) ([]string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	secrets := []string{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			secrets = append(secrets, line)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return nil, errors.New("secret file contains no secrets")
+	}
+
+	return secrets, nil
+}
+
+// NewRelayInfo parses a STUN/TURN URI.
+// Supported query parameters are:
+//   - secret: shared secret for TURN REST API credentials (can be repeated)
+//   - secret-file: path of a file containing one shared secret per line
+//   - algorithm: HMAC algorithm for TURN REST API credentials (sha1, sha256 or sha512)
+//   - realm: realm of the TURN server
+//   - ttl: lifetime of TURN REST API credentials
+//   - group: name of a group used for selecting relays for peers
 func NewRelayInfo(arg string) (RelayInfo, error) {
 	u, user, pass, q, err := stun.ParseURI(arg)
 	if err != nil {
@@ -35,22 +176,44 @@ func NewRelayInfo(arg string) (RelayInfo, error) {
 	}
 
 	r := RelayInfo{
-		URL:      u.String(),
-		Secret:   q.Get("secret"),
-		Username: user,
-		Password: pass,
-		TTL:      DefaultRelayTTL,
+		URL:        u.String(),
+		Realm:      q.Get("realm"),
+		Group:      q.Get("group"),
+		Secrets:    q["secret"],
+		SecretFile: q.Get("secret-file"),
+		Username:   user,
+		Password:   pass,
+		TTL:        DefaultRelayTTL,
+		Algorithm:  RelayAlgorithm(q.Get("algorithm")),
+	}
+
+	if r.Algorithm == "" {
+		r.Algorithm = RelayAlgorithmSHA1
+	} else if _, err := r.Algorithm.hash(); err != nil {
+		return RelayInfo{}, err
 	}
 
 	if t := q.Get("ttl"); t != "" {
 		ttl, err := time.ParseDuration(t)
 		if err != nil {
 			return RelayInfo{}, fmt.Errorf("invalid TTL: %w", err)
+		} else if ttl <= 0 {
+			return RelayInfo{}, fmt.Errorf("invalid TTL: %s must be positive", t)
 		}
 
 		r.TTL = ttl
 	}
 
+	if r.SecretFile != "" {
+		r.secretFile = &secretFile{
+			path: r.SecretFile,
+		}
+
+		if _, err := r.secretFile.read(); err != nil {
+			return RelayInfo{}, err
+		}
+	}
+
 	return r, nil
 }
 
@@ -68,25 +231,117 @@ func NewRelayInfos(args []string) ([]RelayInfo, error) {
 	return relays, nil
 }
 
+// String returns the relay URL with credentials and secrets redacted.
+func (s *RelayInfo) String() string {
+	params := []string{}
+
+	if s.Username != "" {
+		params = append(params, "user="+s.Username)
+	}
+	if s.Password != "" {
+		params = append(params, "pass=redacted")
+	}
+	if n := len(s.inlineSecrets()); n > 0 {
+		params = append(params, fmt.Sprintf("secrets=%d", n))
+	}
+	if s.SecretFile != "" {
+		params = append(params, "secret-file="+s.SecretFile)
+	}
+	if s.Realm != "" {
+		params = append(params, "realm="+s.Realm)
+	}
+	if s.Group != "" {
+		params = append(params, "group="+s.Group)
+	}
+
+	if len(params) == 0 {
+		return s.URL
+	}
+
+	return fmt.Sprintf("%s (%s)", s.URL, strings.Join(params, ", "))
+}
+
+// HasSecrets returns true if the relay issues TURN REST API credentials.
+func (s *RelayInfo) HasSecrets() bool {
+	return len(s.inlineSecrets()) > 0 || s.SecretFile != ""
+}
+
+// inlineSecrets returns the secrets given directly including the deprecated Secret.
+func (s *RelayInfo) inlineSecrets() []string {
+	if s.Secret == "" || slices.Contains(s.Secrets, s.Secret) {
+		return s.Secrets
+	}
+
+	return append(slices.Clone(s.Secrets), s.Secret)
+}
+
+// secrets returns the shared secrets of the relay.
+// Secrets from a secret file take precedence over inline secrets.
+// If the file can not be read, its previous secrets or the inline secrets are used.
+func (s *RelayInfo) secrets() []string {
+	if s.secretFile != nil {
+		if secrets, _ := s.secretFile.Secrets(); len(secrets) > 0 {
+			return secrets
+		}
+	}
+
+	return s.inlineSecrets()
+}
+
+func (s *RelayInfo) sign(secret, user string) string {
+	h, err := s.Algorithm.hash()
+	if err != nil {
+		h = sha1.New
+	}
+
+	digest := hmac.New(h, []byte(secret))
+	digest.Write([]byte(user))
+
+	return base64.StdEncoding.EncodeToString(digest.Sum(nil))
+}
+
 func (s *RelayInfo) GetCredentials(username string) (string, string, time.Time) {
 	if s.Username != "" && s.Password != "" {
 		return s.Username, s.Password, time.Time{}
-	} else if s.Secret != "" {
+	} else if secrets := s.secrets(); len(secrets) > 0 {
 		if s.Username != "" {
 			username = s.Username
 		}
 
 		exp := time.Now().Add(s.TTL)
 		user := fmt.Sprintf("%d:%s", exp.Unix(), username)
-
-		digest := hmac.New(sha1.New, []byte(s.Secret))
-		digest.Write([]byte(user))
-
-		passRaw := digest.Sum(nil)
-		pass := base64.StdEncoding.EncodeToString(passRaw)
+		pass := s.sign(secrets[0], user)
 
 		return user, pass, exp
 	}
 
 	return "", "", time.Time{}
 }
+
+// VerifyCredentials checks TURN REST API credentials against all secrets of the relay
+// and returns their expiry time.
+func (s *RelayInfo) VerifyCredentials(user, pass string) (time.Time, error) {
+	ts, _, ok := strings.Cut(user, ":")
+	if !ok {
+		return time.Time{}, ErrCredentialsInvalid
+	}
+
+	expUnix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrCredentialsInvalid
+	}
+
+	exp := time.Unix(expUnix, 0)
+
+	for _, secret := range s.secrets() {
+		if hmac.Equal([]byte(s.sign(secret, user)), []byte(pass)) {
+			if time.Now().After(exp) {
+				return exp, ErrCredentialsExpired
+			}
+
+			return exp, nil
+		}
+	}
+
+	return exp, ErrCredentialsInvalid
+}
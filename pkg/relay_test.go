@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRelayInfo(t *testing.T) {
+	for _, tc := range []struct {
+		uri       string
+		ttl       time.Duration
+		algorithm RelayAlgorithm
+		secrets   []string
+		ok        bool
+	}{
+		{"turn:turn.example.com?secret=a", DefaultRelayTTL, RelayAlgorithmSHA1, []string{"a"}, true},
+		{"turn:turn.example.com?secret=a&secret=b&ttl=2h", 2 * time.Hour, RelayAlgorithmSHA1, []string{"a", "b"}, true},
+		{"turn:turn.example.com?secret=a&algorithm=sha256", DefaultRelayTTL, RelayAlgorithmSHA256, []string{"a"}, true},
+		{"turn:turn.example.com?secret=a&algorithm=sha512&ttl=90s", 90 * time.Second, RelayAlgorithmSHA512, []string{"a"}, true},
+		{"turn:turn.example.com?secret=a&algorithm=md5", 0, "", nil, false},
+		{"turn:turn.example.com?secret=a&ttl=0s", 0, "", nil, false},
+		{"turn:turn.example.com?secret=a&ttl=-1h", 0, "", nil, false},
+		{"turn:turn.example.com?secret=a&ttl=forever", 0, "", nil, false},
+		{"turn:turn.example.com?secret-file=/nonexistent", 0, "", nil, false},
+		{"http://turn.example.com", 0, "", nil, false},
+	} {
+		ri, err := NewRelayInfo(tc.uri)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("Invalid relay %s has been accepted", tc.uri)
+			}
+
+			continue
+		} else if err != nil {
+			t.Errorf("Failed to parse relay %s: %s", tc.uri, err)
+			continue
+		}
+
+		if ri.TTL != tc.ttl {
+			t.Errorf("Unexpected TTL of %s: %s", tc.uri, ri.TTL)
+		}
+
+		if ri.Algorithm != tc.algorithm {
+			t.Errorf("Unexpected algorithm of %s: %s", tc.uri, ri.Algorithm)
+		}
+
+		if !slices.Equal(ri.secrets(), tc.secrets) {
+			t.Errorf("Unexpected secrets of %s: %v", tc.uri, ri.secrets())
+		}
+
+		if strings.Contains(ri.String(), "secret=") {
+			t.Errorf("Secrets are not redacted: %s", ri.String())
+		}
+	}
+}
+
+func TestRelayCredentialAlgorithms(t *testing.T) {
+	for alg, h := range map[RelayAlgorithm]func() hash.Hash{
+		RelayAlgorithmSHA1:   sha1.New,
+		RelayAlgorithmSHA256: sha256.New,
+		RelayAlgorithmSHA512: sha512.New,
+	} {
+		ri := RelayInfo{
+			Secrets:   []string{"s3cret"},
+			TTL:       time.Hour,
+			Algorithm: alg,
+		}
+
+		user, pass, exp := ri.GetCredentials("alice")
+
+		if !strings.HasSuffix(user, ":alice") || time.Until(exp) > time.Hour || time.Until(exp) < time.Hour-time.Minute {
+			t.Errorf("%s: unexpected credentials: %s (expires %s)", alg, user, exp)
+		}
+
+		// The password is the base64-encoded HMAC of the username
+		digest := hmac.New(h, []byte("s3cret"))
+		digest.Write([]byte(user))
+
+		if want := base64.StdEncoding.EncodeToString(digest.Sum(nil)); pass != want {
+			t.Errorf("%s: unexpected password: got %s, want %s", alg, pass, want)
+		}
+
+		if _, err := ri.VerifyCredentials(user, pass); err != nil {
+			t.Errorf("%s: failed to verify credentials: %s", alg, err)
+		}
+
+		for _, other := range []RelayAlgorithm{RelayAlgorithmSHA1, RelayAlgorithmSHA256, RelayAlgorithmSHA512} {
+			if other == alg {
+				continue
+			}
+
+			ro := ri
+			ro.Algorithm = other
+
+			if _, err := ro.VerifyCredentials(user, pass); !errors.Is(err, ErrCredentialsInvalid) {
+				t.Errorf("%s: credentials have been accepted with %s", alg, other)
+			}
+		}
+	}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	ri := RelayInfo{
+		Secrets: []string{"s3cret"},
+		TTL:     -time.Minute,
+	}
+
+	user, pass, _ := ri.GetCredentials("alice")
+
+	if _, err := ri.VerifyCredentials(user, pass); !errors.Is(err, ErrCredentialsExpired) {
+		t.Errorf("Unexpected error for expired credentials: %v", err)
+	}
+
+	for _, tc := range [][2]string{
+		{"alice", pass},
+		{"never:alice", pass},
+		{user, "invalid"},
+		{user + "x", pass},
+	} {
+		if _, err := ri.VerifyCredentials(tc[0], tc[1]); !errors.Is(err, ErrCredentialsInvalid) {
+			t.Errorf("Unexpected error for %s/%s: %v", tc[0], tc[1], err)
+		}
+	}
+}
+
+// TestSecretRotation checks that credentials are issued with the first secret
+// while those of all secrets are accepted.
+func TestSecretRotation(t *testing.T) {
+	old := RelayInfo{Secrets: []string{"old"}, TTL: time.Hour}
+	rotated := RelayInfo{Secrets: []string{"new", "old"}, TTL: time.Hour}
+	next := RelayInfo{Secrets: []string{"new"}, TTL: time.Hour}
+
+	user, pass, _ := old.GetCredentials("alice")
+	if _, err := rotated.VerifyCredentials(user, pass); err != nil {
+		t.Errorf("Credentials of the previous secret have been rejected: %s", err)
+	}
+
+	user, pass, _ = rotated.GetCredentials("alice")
+	if _, err := next.VerifyCredentials(user, pass); err != nil {
+		t.Errorf("Credentials have not been issued with the first secret: %s", err)
+	}
+
+	// The deprecated single secret is accepted as well
+	legacy := RelayInfo{Secrets: []string{"new"}, Secret: "old", TTL: time.Hour}
+
+	user, pass, _ = old.GetCredentials("alice")
+	if _, err := legacy.VerifyCredentials(user, pass); err != nil {
+		t.Errorf("Credentials of the deprecated secret have been rejected: %s", err)
+	}
+
+	if !legacy.HasSecrets() || !(&RelayInfo{Secret: "old"}).HasSecrets() {
+		t.Error("Relay with deprecated secret has no secrets")
+	}
+}
+
+// TestSecretFileRotation checks that changes of a secret file are picked up
+// and that the previous secrets are kept if the file can not be read.
+func TestSecretFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write secret file: %s", err)
+		}
+
+		// Make sure that the change is detected independent of the timestamp resolution
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to change time of secret file: %s", err)
+		}
+	}
+
+	now := time.Now()
+
+	write("# comment\nold\n", now.Add(-time.Hour))
+
+	ri, err := NewRelayInfo("turn:turn.example.com?secret=inline&secret-file=" + path)
+	if err != nil {
+		t.Fatalf("Failed to parse relay: %s", err)
+	}
+
+	if got := ri.secrets(); !slices.Equal(got, []string{"old"}) {
+		t.Fatalf("Unexpected secrets: %v", got)
+	}
+
+	write("new\nold\n", now)
+
+	if got := ri.secrets(); !slices.Equal(got, []string{"new", "old"}) {
+		t.Fatalf("Changed secrets have not been read: %v", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove secret file: %s", err)
+	}
+
+	if got := ri.secrets(); !slices.Equal(got, []string{"new", "old"}) {
+		t.Fatalf("Previous secrets have not been kept: %v", got)
+	}
+
+	write("\n", now.Add(time.Hour))
+
+	if _, err := ReadSecretFile(path); err == nil {
+		t.Fatal("Secret file without secrets has been accepted")
+	}
+}
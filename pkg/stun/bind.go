@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package stun
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+)
+
+var (
+	ErrTimeout = errors.New("timed out")
+)
+
+// Dial opens a connection to the server of the URI for use by a TURN client.
+// Stream connections are framed as STUN messages (RFC 5389 section 7.2.2).
+// It returns the connection and the address of the server.
+// TURN over DTLS is not supported.
+func Dial(uri *stun.URI, timeout time.Duration) (net.PacketConn, string, error) {
+	addr := net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))
+	secure := uri.Scheme == stun.SchemeTypeSTUNS || uri.Scheme == stun.SchemeTypeTURNS
+	dialer := &net.Dialer{Timeout: timeout}
+
+	switch {
+	case uri.Proto == stun.ProtoTypeUDP && !secure:
+		conn, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			return nil, "", err
+		}
+
+		return conn, addr, nil
+
+	case uri.Proto == stun.ProtoTypeTCP && !secure:
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return turn.NewSTUNConn(conn), addr, nil
+
+	case uri.Proto == stun.ProtoTypeTCP && secure:
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName: uri.Host,
+			MinVersion: tls.VersionTLS12,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		return turn.NewSTUNConn(conn), addr, nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported transport: %s", uri.Proto)
+	}
+}
+
+// Bind sends a STUN binding request to the server of the URI and returns the mapped address.
+// TURN URIs are supported as TURN servers answer binding requests as well.
+// Requests over UDP are retransmitted until the timeout expires.
+func Bind(uri *stun.URI, timeout time.Duration) (net.Addr, error) {
+	conn, addr, err := Dial(uri, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: addr,
+		Conn:           conn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Listen(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	type result struct {
+		addr net.Addr
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		addr, err := client.SendBindingRequest()
+		done <- result{addr, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		client.Close()
+		return res.addr, res.err
+
+	case <-timer.C:
+		// Closing the client aborts the pending transaction
+		client.Close()
+		<-done
+		return nil, ErrTimeout
+	}
+}
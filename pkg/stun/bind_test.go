@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2023 Institute for Automation of Complex Power Systems
+// SPDX-License-Identifier: Apache-2.0
+
+package stun
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+)
+
+// startServer starts a STUN server on the loopback interface which listens for UDP and TCP.
+// It returns the addresses of both listeners.
+func startServer(t *testing.T) (string, string) {
+	t.Helper()
+
+	udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	tcp, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+
+	gen := &turn.RelayAddressGeneratorStatic{
+		RelayAddress: net.ParseIP("127.0.0.1"),
+		Address:      "127.0.0.1",
+	}
+
+	srv, err := turn.NewServer(turn.ServerConfig{
+		AuthHandler: func(string, string, net.Addr) ([]byte, bool) {
+			return nil, false
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn:            udp,
+				RelayAddressGenerator: gen,
+			},
+		},
+		ListenerConfigs: []turn.ListenerConfig{
+			{
+				Listener:              tcp,
+				RelayAddressGenerator: gen,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start server: %s", err)
+	}
+
+	t.Cleanup(func() {
+		srv.Close() //nolint:errcheck
+	})
+
+	return udp.LocalAddr().String(), tcp.Addr().String()
+}
+
+func TestBind(t *testing.T) {
+	udp, tcp := startServer(t)
+
+	// A server which never answers
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer silent.Close()
+
+	for _, tc := range []struct {
+		uri string
+		err error
+	}{
+		{fmt.Sprintf("stun:%s", udp), nil},
+		{fmt.Sprintf("turn:%s?transport=udp", udp), nil},
+		{fmt.Sprintf("turn:%s?transport=tcp", tcp), nil},
+		{fmt.Sprintf("stun:%s", silent.LocalAddr()), ErrTimeout},
+	} {
+		uri, err := stun.ParseURI(tc.uri)
+		if err != nil {
+			t.Fatalf("Failed to parse URI %s: %s", tc.uri, err)
+		}
+
+		start := time.Now()
+		addr, err := Bind(uri, 500*time.Millisecond)
+
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%s: unexpected error: %v", tc.uri, err)
+			}
+
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("%s: timeout took %s", tc.uri, d)
+			}
+
+			continue
+		} else if err != nil {
+			t.Errorf("%s: binding failed: %s", tc.uri, err)
+			continue
+		}
+
+		if ua, ok := addr.(*net.UDPAddr); !ok || !ua.IP.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("%s: unexpected mapped address: %s", tc.uri, addr)
+		}
+	}
+}
+
+func TestDialUnsupported(t *testing.T) {
+	uri, err := stun.ParseURI("turns:127.0.0.1:5349?transport=udp")
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %s", err)
+	}
+
+	if _, _, err := Dial(uri, time.Second); err == nil {
+		t.Fatal("DTLS is supported")
+	}
+}